@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Blooym/proto/core"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache <cmd>",
+	Short: "Manage the download cache",
+	Long: `Manage the download cache, which keeps every downloaded runner archive so that installing the same release again (eg. to both Steam and Lutris) doesn't download it again.
+Archives are only cached once they have passed checksum and signature verification, and are checked against their recorded digest every time they are reused.
+Use the --no-cache flag of the install command to download an archive again regardless.`,
+}
+
+var listCacheCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the archives in the download cache",
+	Aliases: []string{"ls"},
+	Example: "proto cache list",
+	Args:    cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		archives, err := core.ListCachedArchives()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if len(archives) == 0 {
+			fmt.Println("The download cache is empty.")
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Source", "Tag", "Archive", "Size", "Last Used"})
+
+		// Archives with the same contents are only stored once, so only count them towards the total once.
+		var totalSize int64
+		counted := map[string]bool{}
+		for _, archive := range archives {
+			size, unit := core.HumanReadableBytes(archive.Size)
+			if !counted[archive.Path()] {
+				totalSize += archive.Size
+				counted[archive.Path()] = true
+			}
+			table.Append([]string{archive.Source, archive.Tag, archive.Asset, fmt.Sprintf("%v%s", size, unit), archive.UsedAt.Format("2006-01-02")})
+		}
+
+		tSize, tUnit := core.HumanReadableBytes(totalSize)
+		table.SetFooter([]string{"Total", " ", " ", fmt.Sprintf("%v%s", tSize, tUnit), " "})
+		table.Render()
+		fmt.Println("Location: " + core.GetArchiveCacheDir())
+	},
+}
+
+var cleanCacheCmd = &cobra.Command{
+	Use:     "clean",
+	Short:   "Remove every archive from the download cache",
+	Example: "proto cache clean",
+	Args:    cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Prevent the cache from being cleaned while an install is using it.
+		lock := core.HandleLock()
+		defer lock.Unlock()
+
+		if RootCmd.Flag("yes").Value.String() != "true" {
+			if !core.Prompt("Are you sure you want to remove every archive from the download cache? (y/N) ", false) {
+				os.Exit(0)
+			}
+		}
+
+		freed, err := core.CleanArchiveCache()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		size, unit := core.HumanReadableBytes(freed)
+		fmt.Printf("The download cache has been cleaned, freeing %v%s.\n", size, unit)
+	},
+}
+
+var pruneCacheCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Shrink the download cache",
+	Long: `Shrink the download cache to the size given by --max-size, removing the least recently used archives first.
+Unfinished downloads are always removed, use a --max-size of 0 to only remove those.`,
+	Example: "proto cache prune --max-size 2GB",
+	Args:    cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Prevent the cache from being pruned while an install is using it.
+		lock := core.HandleLock()
+		defer lock.Unlock()
+
+		maxSizeFlag, _ := cmd.Flags().GetString("max-size")
+		maxSize, err := core.ParseByteSize(maxSizeFlag)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		// A max size of 0 only removes unfinished downloads, rather than every archive.
+		if maxSize == 0 {
+			maxSize = 1<<63 - 1
+		}
+
+		removed, freed, err := core.PruneArchiveCache(maxSize)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, archive := range removed {
+			fmt.Printf("Removed %s (%s)\n", archive.Asset, archive.Source)
+		}
+
+		size, unit := core.HumanReadableBytes(freed)
+		fmt.Printf("The download cache has been pruned, freeing %v%s.\n", size, unit)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cacheCmd)
+
+	cacheCmd.AddCommand(listCacheCmd)
+	cacheCmd.AddCommand(cleanCacheCmd)
+	cacheCmd.AddCommand(pruneCacheCmd)
+
+	pruneCacheCmd.Flags().String("max-size", "0", "The maximum size of the download cache (eg. 500MB or 2GB).")
+}
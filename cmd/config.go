@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Blooym/proto/core"
 	"github.com/spf13/cobra"
@@ -81,14 +84,79 @@ var tempCmd = &cobra.Command{
 	},
 }
 
+var cacheTTLCmd = &cobra.Command{
+	Use:   "cache-ttl <duration>",
+	Short: "Change how long release data is cached for",
+	Long: `Change how long release data fetched from sources is used before Proto checks the source for changes again.
+Checking for changes is cheap as unchanged data is not downloaded again (and does not count against GitHub API rate limits), use 0s to always check.
+Cached data can be used without any network access by passing the --offline flag to any command.`,
+	Example: "proto config cache-ttl 1h",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := time.ParseDuration(args[0]); err != nil {
+			fmt.Println("Invalid duration, expected a value such as 30m or 1h")
+			os.Exit(1)
+		}
+
+		viper.Set("cache.ttl", args[0])
+		viper.WriteConfig()
+		fmt.Println("Cache time to live has been set to: " + args[0])
+	},
+}
+
+var downloadTimeoutCmd = &cobra.Command{
+	Use:   "download-timeout <connect> <read>",
+	Short: "Change how long downloads wait for a server",
+	Long: `Change how long Proto waits to connect to a server, and how long it waits for more data once a download has started.
+A download that times out is retried, continuing from where it stopped if the server supports it. Slow but steady downloads never time out.`,
+	Example: "proto config download-timeout 30s 2m",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, arg := range args {
+			if duration, err := time.ParseDuration(arg); err != nil || duration <= 0 {
+				fmt.Println("Invalid duration " + arg + ", expected a value such as 30s or 2m")
+				os.Exit(1)
+			}
+		}
+
+		viper.Set("download.connecttimeout", args[0])
+		viper.Set("download.readtimeout", args[1])
+		viper.WriteConfig()
+		fmt.Println("Download timeouts have been set to: " + args[0] + " (connect) and " + args[1] + " (read)")
+	},
+}
+
+var downloadRetriesCmd = &cobra.Command{
+	Use:   "download-retries <count>",
+	Short: "Change how many times failed downloads are retried",
+	Long: `Change how many times Proto retries a download that fails, waiting a little longer between every attempt (1s, 2s, 4s... up to 30s).
+Retries continue from where the download stopped if the server supports it, use 0 to never retry.`,
+	Example: "proto config download-retries 10",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if retries, err := strconv.Atoi(args[0]); err != nil || retries < 0 {
+			fmt.Println("Invalid number of retries, expected a number such as 5")
+			os.Exit(1)
+		}
+
+		viper.Set("download.retries", args[0])
+		viper.WriteConfig()
+		fmt.Println("Download retries have been set to: " + args[0])
+	},
+}
+
 var forceCmd = &cobra.Command{
 	Use:   "force <bool>",
-	Short: "Forces installations to go ahead regardless of missing or invalid checksums",
-	Long: `Enable or disable forcing installations regardless of missing or invalid checksums.
-When disabled, Proto will not allow you to download a file unless it has a valid checksum, if the release does not provide a checksum then it cannot be downloaded.
-This is useful for ensuring that the file you downloaded is the same as the one that the author uploaded.
+	Short: "Forces installations to abort on failed checksums and to go ahead without a required signature",
+	Long: `Enable or disable forcing installations, the same as always passing --force to install.
+This is what happens to an archive that can't be verified, with force disabled (the default) and enabled:
+  - No checksum file: a warning is shown and the install continues either way.
+  - Checksum mismatch: disabled asks whether to continue, or continues with a warning when -y is used. Enabled aborts.
+  - sha1 and md5 checksums: only ever warned about, as they can't detect tampering.
+  - No signature, for a source with trusted keys: disabled aborts. Enabled continues with a warning.
+  - Bad signature: the install is always refused, neither force nor -y can override it.
 Please note that this does not mean that the file is safe to run if it passes, and you should always trust the source you download from.`,
-	Example:   "proto config force-sum true",
+	Example:   "proto config force true",
 	Args:      cobra.ExactArgs(1),
 	ValidArgs: []string{"true", "false"},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -98,6 +166,37 @@ Please note that this does not mean that the file is safe to run if it passes, a
 	},
 }
 
+var githubTokenCmd = &cobra.Command{
+	Use:   "github-token <token>",
+	Short: "Set the token used to authenticate with the GitHub API",
+	Long: `Set the token used to authenticate with the GitHub API, which raises the rate limit from 60 to 5000 requests per hour.
+The token is stored in its own credentials file (only readable by you) rather than the configuration file, pass an empty string to remove it.
+The PROTO_GITHUB_TOKEN and GITHUB_TOKEN environment variables take priority over the stored token, followed by the auth.githubtoken config value.`,
+	Example: `proto config github-token ghp_xxxxxxxxxxxx
+proto config github-token ""`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		credentialsFile := core.UsePath(viper.GetString("auth.githubcredentials"), false)
+
+		if args[0] == "" {
+			if err := os.Remove(credentialsFile); err != nil && !os.IsNotExist(err) {
+				core.CheckError(err)
+			}
+
+			fmt.Println("GitHub token has been removed.")
+			return
+		}
+
+		err := os.MkdirAll(filepath.Dir(credentialsFile), os.ModePerm)
+		core.CheckError(err)
+
+		err = ioutil.WriteFile(credentialsFile, []byte(strings.TrimSpace(args[0])+"\n"), 0600)
+		core.CheckError(err)
+
+		fmt.Println("GitHub token has been saved to: " + credentialsFile)
+	},
+}
+
 var locationsCmd = &cobra.Command{
 	Use:   "locations <cmd>",
 	Short: "Manage your custom directory mappings",
@@ -184,21 +283,128 @@ var resetCmd = &cobra.Command{
 var sourcesCmd = &cobra.Command{
 	Use:   "sources <cmd>",
 	Short: "Modify the sources list",
-	Long:  `Sources are public GitHub repositories that Proto uses to find releases for you. Make sure you only add sources that you trust.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Sources are public repositories that Proto uses to find releases for you. Make sure you only add sources that you trust.
+Sources are GitHub repositories by default, other hosts can be used by prefixing the source with its type:
+  - owner/repo or github:owner/repo (GitHub)
+  - ghe.example.com/owner/repo (GitHub Enterprise Server, see the --api-url, --upload-url and --token-env flags of the add command)
+  - gitlab:group/project or gitlab:https://gitlab.example.com/group/project (GitLab)
+  - gitea:owner/repo or gitea:https://gitea.example.com/owner/repo (Gitea, Forgejo and Codeberg, defaults to Codeberg)
+  - index:https://mirror.example.com/index.json or index:file:///srv/mirror/index.yaml (A static JSON or YAML release index)
+  - dir:/mnt/runners (A local or network mounted directory of runner archives, with optional checksum files such as .sha512sum or .sha256sum next to them)
+
+A release index lists releases and their assets, asset URLs may be relative to the index. Checksums are prefixed with their
+algorithm (sha512, sha256, blake2b, blake3, sha1 or md5), the older "sha512: <hex digest>" field is still supported:
+  releases:
+    - tag: GE-Proton8-25
+      date: 2023-11-24
+      assets:
+        - name: GE-Proton8-25.tar.gz
+          url: GE-Proton8-25.tar.gz
+          size: 447351123
+          checksum: sha256:<hex digest>
+
+Sources can be given a name with the --name flag, which can then be used with the --source flag of other commands instead of its index.
+
+When a release has several runner archives or checksum files, the --include, --exclude, --sum-include and --sum-exclude flags
+of the add command choose which ones are used. Patterns are globs matched against the asset name (eg. "*-debug.tar.gz"),
+or regular expressions when prefixed with "re:" (eg. "re:^wine-lutris-.*x86_64"). Any archives still left over are chosen
+from when installing, or picked with the --asset flag of the install command.
+
+Releases can be required to be signed by giving the source trusted public keys with the --minisign-key, --gpg-key and
+--cosign-key flags of the add command (a key file, or the key itself for minisign). Installs are then refused unless
+the archive or its checksum file has a detached signature (.minisig, .asc, .sig, or a cosign/Sigstore bundle) made by one of them.
+Cosign bundles are checked against the key only, keyless signatures and transparency log entries are not verified.`,
+	Args: cobra.ExactArgs(1),
 }
 
 var addSourceCmd = &cobra.Command{
-	Use:     "add <owner/repo>",
-	Short:   "Add a source to the list",
-	Example: "add GloriousEggroll/proton-ge-custom",
-	Args:    cobra.ExactArgs(1),
+	Use:   "add <source>",
+	Short: "Add a source to the list",
+	Example: `add GloriousEggroll/proton-ge-custom
+add ghe.example.com/team/proton-fork --api-url https://ghe.example.com/api/v3/ --token-env GHE_TOKEN
+add gitlab:https://gitlab.example.com/group/project
+add gitea:https://codeberg.org/owner/repo
+add index:https://mirror.example.com/proton/index.json --name mirror
+add dir:/mnt/runners --name airgap
+add GloriousEggroll/wine-ge-custom --include "wine-lutris-*" --exclude "*-debug.tar.xz"
+add owner/repo --minisign-key RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		// Attach any enterprise endpoints and token variable to the source entry.
+		for flag, option := range map[string]string{"api-url": "api", "upload-url": "upload", "token-env": "token-env"} {
+			value, _ := cmd.Flags().GetString(flag)
+			if value == "" {
+				continue
+			}
+
+			entry, err := core.SetSourceOption(args[0], option, value)
+			core.CheckError(err)
+			args[0] = entry
+		}
+
+		// Attach any asset patterns to the source entry.
+		for _, option := range []string{"include", "exclude", "sum-include", "sum-exclude"} {
+			patterns, _ := cmd.Flags().GetStringArray(option)
+			if len(patterns) == 0 {
+				continue
+			}
+
+			entry, err := core.SetSourceOptionValues(args[0], option, patterns)
+			core.CheckError(err)
+			args[0] = entry
+		}
+
+		// Attach any trusted keys to the source entry, key files are stored by their absolute path.
+		for _, option := range []string{"minisign-key", "gpg-key", "cosign-key"} {
+			keys, _ := cmd.Flags().GetStringArray(option)
+			if len(keys) == 0 {
+				continue
+			}
+
+			for i, key := range keys {
+				if _, err := os.Stat(core.UsePath(key, false)); err == nil {
+					keys[i], _ = filepath.Abs(core.UsePath(key, false))
+				}
+			}
+
+			entry, err := core.SetSourceOptionValues(args[0], option, keys)
+			core.CheckError(err)
+			args[0] = entry
+		}
+
+		// Attach the name to the source entry if one was given.
+		name, _ := cmd.Flags().GetString("name")
+		if name != "" {
+			if strings.ContainsAny(name, " /?&=") {
+				fmt.Println("Source name cannot contain spaces or any of the characters / ? & =")
+				os.Exit(1)
+			}
+
+			if _, err := strconv.Atoi(name); err == nil {
+				fmt.Println("Source name cannot be a number, as it would be confused with a source index")
+				os.Exit(1)
+			}
+
+			entry, err := core.SetSourceOption(args[0], "name", name)
+			core.CheckError(err)
+			args[0] = entry
+		}
+
+		if _, err := core.ParseSource(args[0]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
 		for _, v := range viper.GetStringSlice("app.sources") {
 			if v == args[0] {
 				fmt.Println("Source already exists")
 				return
 			}
+
+			if name != "" && core.GetSourceName(v) == name {
+				fmt.Println("A source named " + name + " already exists")
+				return
+			}
 		}
 
 		viper.Set("app.sources", append(viper.GetStringSlice("app.sources"), args[0]))
@@ -208,7 +414,7 @@ var addSourceCmd = &cobra.Command{
 }
 
 var delSourceCmd = &cobra.Command{
-	Use:     "del <owner/repo>",
+	Use:     "del <source|name>",
 	Short:   "Remove a source from the list",
 	Example: "del GloriousEggroll/proton-ge-custom",
 	Aliases: []string{"del", "remove", "rm"},
@@ -217,7 +423,7 @@ var delSourceCmd = &cobra.Command{
 		var sources = viper.GetStringSlice("app.sources")
 
 		for i, source := range sources {
-			if source == args[0] {
+			if source == args[0] || core.GetSourceName(source) == args[0] {
 				sources = append(sources[:i], sources[i+1:]...)
 				break
 			}
@@ -241,8 +447,8 @@ var listSourcesCmd = &cobra.Command{
 		}
 
 		fmt.Println("Currently configured sources:")
-		for _, v := range sources {
-			fmt.Println("- " + v)
+		for i, v := range sources {
+			fmt.Printf("%d. %s\n", i+1, v)
 		}
 	},
 }
@@ -254,7 +460,11 @@ func init() {
 	configCmd.AddCommand(configDirCmd)
 	configCmd.AddCommand(tempCmd)
 	configCmd.AddCommand(forceCmd)
+	configCmd.AddCommand(cacheTTLCmd)
+	configCmd.AddCommand(downloadTimeoutCmd)
+	configCmd.AddCommand(downloadRetriesCmd)
 	configCmd.AddCommand(verboseCmd)
+	configCmd.AddCommand(githubTokenCmd)
 	configCmd.AddCommand(sourcesCmd)
 	configCmd.AddCommand(locationsCmd)
 	configCmd.AddCommand(resetCmd)
@@ -263,6 +473,18 @@ func init() {
 	sourcesCmd.AddCommand(delSourceCmd)
 	sourcesCmd.AddCommand(listSourcesCmd)
 
+	addSourceCmd.Flags().StringP("name", "n", "", "A name to refer to the source by with the --source flag")
+	addSourceCmd.Flags().String("api-url", "", "The API base URL of a GitHub Enterprise Server source")
+	addSourceCmd.Flags().String("upload-url", "", "The upload URL of a GitHub Enterprise Server source")
+	addSourceCmd.Flags().String("token-env", "", "The environment variable holding the token for a GitHub Enterprise Server source")
+	addSourceCmd.Flags().StringArray("include", nil, "Only use runner archives matching this glob or re: prefixed regular expression (can be repeated)")
+	addSourceCmd.Flags().StringArray("exclude", nil, "Never use runner archives matching this glob or re: prefixed regular expression (can be repeated)")
+	addSourceCmd.Flags().StringArray("sum-include", nil, "Only use checksum files matching this glob or re: prefixed regular expression (can be repeated)")
+	addSourceCmd.Flags().StringArray("sum-exclude", nil, "Never use checksum files matching this glob or re: prefixed regular expression (can be repeated)")
+	addSourceCmd.Flags().StringArray("minisign-key", nil, "A trusted minisign public key (or key file) that releases must be signed with (can be repeated)")
+	addSourceCmd.Flags().StringArray("gpg-key", nil, "A trusted GPG public key file that releases must be signed with (can be repeated)")
+	addSourceCmd.Flags().StringArray("cosign-key", nil, "A trusted cosign public key file that releases must be signed with (can be repeated)")
+
 	locationsCmd.AddCommand(addLocationCmd)
 	locationsCmd.AddCommand(deleteLocationCmd)
 	locationsCmd.AddCommand(listLocationsCmd)
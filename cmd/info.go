@@ -6,7 +6,6 @@ import (
 
 	"github.com/Blooym/proto/core"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var infoCmd = &cobra.Command{
@@ -16,20 +15,8 @@ var infoCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		// If there are multiple sources, ask the user which one to use or use the flag.
-		var source int
-		sourceFlag, _ := cmd.Flags().GetInt("source")
-		if sourceFlag > 0 {
-			sourceLen := len(viper.GetStringSlice("app.sources"))
-
-			if sourceFlag-1 >= sourceLen {
-				fmt.Println("There is no source at index", sourceFlag, "you only have", sourceLen, "sources.")
-				os.Exit(1)
-			}
-
-			source = sourceFlag - 1
-		} else {
-			source = core.PromptSourceIndex()
-		}
+		sourceFlag, _ := cmd.Flags().GetString("source")
+		source := core.SelectSourceIndex(sourceFlag)
 
 		// Fetch the release data.
 		data, err := core.GetReleaseData(source, args[0])
@@ -40,15 +27,15 @@ var infoCmd = &cobra.Command{
 		}
 
 		// Print the release data.
-		fmt.Println("Release:", data.GetTagName())
-		fmt.Println("Published:", data.GetPublishedAt().Format("2006-01-02 15:04:05"))
-		fmt.Println("Description:", data.GetBody())
-		fmt.Println("Install Command: proto install", data.GetTagName(), "-s", source+1, "-d", "<install-dir>")
+		fmt.Println("Release:", data.TagName)
+		fmt.Println("Published:", data.PublishedAt.Format("2006-01-02 15:04:05"))
+		fmt.Println("Description:", data.Body)
+		fmt.Println("Install Command: proto install", data.TagName, "-s", source+1, "-d", "<install-dir>")
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(infoCmd)
 
-	infoCmd.Flags().IntP("source", "s", 0, "The index of the source to use.")
+	infoCmd.Flags().StringP("source", "s", "", "The index or name of the source to use.")
 }
@@ -1,19 +1,39 @@
 package cmd
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/Blooym/proto/core"
 
-	"github.com/google/go-github/v44/github"
 	cobra "github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+// Verification runs once the archive has been downloaded and extracted, and returns these to stop the install without any changes.
+// The free space check returns errInsufficientSpace before anything is downloaded.
+var (
+	errInstallCancelled   = errors.New("the install was cancelled")
+	errVerificationFailed = errors.New("the archive failed verification")
+	errInsufficientSpace  = errors.New("there is not enough free space for the install")
+)
+
 var installCmd = &cobra.Command{
 	Use:   "install [tag]",
 	Short: "Download and install runner to your system.",
+	Example: `proto install GE-Proton8-25 --dir steam
+proto install wine-lutris-GE-Proton8-26 --asset "*-x86_64.tar.xz" --dir lutris
+proto install --file ./GE-Proton8-25.tar.gz --sum-file ./GE-Proton8-25.sha512sum --dir steam
+proto install --url https://ci.example.com/GE-Proton8-25.tar.gz --checksum sha256:<digest> --dir steam`,
 	PreRun: func(cmd *cobra.Command, args []string) {
 		core.DeleteUserTemp()
 	},
@@ -34,6 +54,19 @@ var installCmd = &cobra.Command{
 		}
 		installDir = core.UsePath(core.GetCustomLocation(installDir), true)
 
+		// Installing from an archive outside of a source skips the fetch logic entirely.
+		fileFlag, _ := cmd.Flags().GetString("file")
+		urlFlag, _ := cmd.Flags().GetString("url")
+		if fileFlag != "" || urlFlag != "" {
+			if len(args) > 0 {
+				fmt.Println("A tag cannot be given alongside the --file or --url flags.")
+				os.Exit(1)
+			}
+
+			installArchive(cmd, installDir, fileFlag, urlFlag)
+			return
+		}
+
 		/**
 		----------------------
 		|     Fetch Logic    |
@@ -41,29 +74,25 @@ var installCmd = &cobra.Command{
 		**/
 
 		// If there are multiple sources, ask the user which one to use or use the flag.
-		var source int
-		sourceFlag, _ := cmd.Flags().GetInt("source")
-		if sourceFlag > 0 {
-			sourceLen := len(viper.GetStringSlice("app.sources"))
+		sourceFlag, _ := cmd.Flags().GetString("source")
+		source := core.SelectSourceIndex(sourceFlag)
 
-			if sourceFlag-1 >= sourceLen {
-				fmt.Println("There is no source at index", sourceFlag, "you only have", sourceLen, "sources.")
-				os.Exit(1)
-			}
-
-			source = sourceFlag - 1
-		} else {
-			source = core.PromptSourceIndex()
-		}
+		src, err := core.GetSource(source)
+		core.CheckError(err)
 
 		// Find the version to install, if none is specified, use the latest.
-		var tagData *github.RepositoryRelease
+		var tagData *core.Release
 		switch len(args) {
 		case 0: // Install latest tag.
-			data, err := core.GetReleases(source)
+			data, err := core.GetReleases(source, 1)
 			if err != nil {
 				panic(err)
 			}
+
+			if len(data) == 0 {
+				fmt.Println("The source does not have any releases to install.")
+				os.Exit(1)
+			}
 			tagData = data[0]
 		default: // Install a specific tag.
 			data, err := core.GetReleaseData(source, args[0])
@@ -73,132 +102,589 @@ var installCmd = &cobra.Command{
 			tagData = data
 		}
 
-		yesFlag := RootCmd.Flag("yes").Value.String()
-		s, m := core.HumanReadableBytes(core.GetTotalAssetSize(tagData.Assets))
+		_, err = installRelease(cmd, source, src, tagData, installDir, true)
+		exitOnInstallError(err)
+	},
+}
 
-		/**
-		----------------------
-		|    Overlap Logic   |
-		----------------------
-		**/
+/*
+installRelease downloads, verifies and installs a runner from the given release of a source.
+Arguments:
+
+	cmd<*cobra.Command>: The command being run, for the --asset and --no-cache flags if it has them.
+	source<int>: The index of the source.
+	src<core.Source>: The source.
+	release<*core.Release>: The release to install.
+	installDir<string>: The directory to install the runner to.
+	confirm<bool>: Whether to ask the user to confirm the install (unless the -y flag is set).
+
+Returns:
+
+	[]string: The paths of the installed runners.
+	error: An error if the install failed, errInstallCancelled, errVerificationFailed or errInsufficientSpace if the reason has already been shown.
+*/
+func installRelease(cmd *cobra.Command, source int, src core.Source, release *core.Release, installDir string, confirm bool) ([]string, error) {
+	assets, err := src.ListAssets(context.Background(), release)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch valid assets from the release, narrowed down by the source's asset patterns and the --asset flag.
+	filter, err := core.GetAssetFilter(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if assetFlag, _ := cmd.Flags().GetString("asset"); assetFlag != "" {
+		filter, err = filter.WithAsset(assetFlag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tar, sum, err := core.GetValidAssets(assets, filter, RootCmd.Flag("yes").Value.String() != "true")
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []*core.Asset{tar}
+	if sum != nil {
+		selected = append(selected, sum)
+	}
+
+	s, m := core.HumanReadableBytes(core.GetTotalAssetSize(selected))
+
+	/**
+	----------------------
+	|    Overlap Logic   |
+	----------------------
+	**/
+
+	if confirm {
+		confirmInstall(installDir, release.TagName, fmt.Sprintf("%v%s", s, m))
+	}
+
+	/**
+	----------------------
+	|   Download Logic   |
+	----------------------
+	**/
+
+	if sum == nil && !viper.GetBool("app.force") {
+		fmt.Println("No checksum file was found for this release, skipping checksum verification.")
+	}
+
+	// Download the assets to the temp directory.
+	tmp, err := core.GetUserTemp()
+	if err != nil {
+		return nil, err
+	}
+
+	// Archives that are already on disk (eg. from a "dir:" source) are installed from where they are. Otherwise use the archive
+	// from the cache if it has been downloaded before, or download it to the cache.
+	noCache, _ := cmd.Flags().GetBool("no-cache")
+	archivePath, local := core.GetLocalAssetPath(src, tar)
+	cached := false
+	if !local && !noCache {
+		archivePath, cached = core.GetCachedArchive(src.String(), release.TagName, tar.Name)
+	}
+
+	if cached {
+		fmt.Println("Using " + tar.Name + " from the download cache.")
+	} else if !local {
+		archivePath = core.GetArchiveDownloadPath(src.String(), release.TagName, tar.Name)
+	}
+
+	// Make sure the download and the extracted files will fit before downloading anything.
+	requirements := []core.SpaceRequirement{}
+	if sum != nil {
+		requirements = append(requirements, core.SpaceRequirement{Path: tmp, Size: sum.Size})
+	}
+
+	if local || cached {
+		installSize, err := core.GetInstallSize(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, core.SpaceRequirement{Path: installDir, Size: installSize})
+	} else {
+		requirements = append(requirements,
+			core.SpaceRequirement{Path: filepath.Dir(archivePath), Size: core.GetRemainingDownloadSize(archivePath, tar.Size)},
+			core.SpaceRequirement{Path: installDir, Size: core.EstimateInstallSize(tar.Name, tar.Size)},
+		)
+	}
+	if err := checkFreeSpace(requirements...); err != nil {
+		return nil, err
+	}
+
+	/**
+	----------------------
+	|   Checksum Logic   |
+	----------------------
+	**/
+
+	// If it exists, download the checksum file first so the archive can be hashed while it is downloaded and extracted.
+	var verifier *core.ChecksumVerifier
+	if sum != nil {
+		if _, err := core.DownloadAsset(tmp+sum.Name, src, sum); err != nil {
+			return nil, err
+		}
 
-		// Check if the directory exists already, meaning we're trying to install a version that's already installed.
-		if folderInfo, err := os.Stat(installDir + tagData.GetTagName()); err == nil && folderInfo.IsDir() {
-			// Prompt the user for to overwrite the existing version, skipped if -y flag is set.
-			if yesFlag != "true" {
-				resp := core.Prompt(fmt.Sprintf("Looks like %s is already installed, overwrite? [Est. %v%s] (y/N) ", tagData.GetTagName(), s, m), false)
+		// A checksum file that doesn't list the archive (eg. a SHA256SUMS covering other assets) is the same as having none.
+		verifier, err = core.NewChecksumFileVerifier(tmp+sum.Name, tar.Name)
+		var missing *core.MissingChecksumError
+		if errors.As(err, &missing) {
+			if !viper.GetBool("app.force") {
+				fmt.Println("Warning! " + missing.Error() + ", skipping checksum verification.")
+			}
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	/**
+	----------------------
+	|  Signature Logic   |
+	----------------------
+	**/
+
+	// Sources with trusted keys must sign their releases.
+	policy, err := core.GetTrustPolicy(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing is installed until the archive has been fully downloaded and passed verification.
+	verify := func() error {
+		sumVerified := false
+		if verifier != nil {
+			result := verifier.Result()
+			if err := handleChecksumResult(result, fmt.Sprintf("%v%s", s, m)); err != nil {
+				return err
+			}
+			sumVerified = result.Match && !result.Weak
+		}
 
-				if !resp {
-					os.Exit(0)
-				}
+		if policy.Enabled() {
+			if err := verifySignature(src, policy, assets, tmp, archivePath, tar, sum, sumVerified); err != nil {
+				return err
 			}
+		}
 
-			// Remove the existing directory.
-			if err := os.RemoveAll(installDir + tagData.GetTagName()); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+		// Only keep archives that passed verification for the next install.
+		if !local && !cached {
+			if _, err := core.CacheArchive(src.String(), release.TagName, tar.Name, archivePath); err != nil {
+				core.Debug("Unable to add " + tar.Name + " to the download cache: " + err.Error())
 			}
+		}
 
-			fmt.Println("Removed old installation: " + tagData.GetTagName())
-		} else if yesFlag != "true" {
-			// Prompt the user to confirm the install, skipped if -y flag is set.
-			resp := core.Prompt(fmt.Sprintf("Are you sure you want to install %s? [Est. %v%s] (y/N) ", tagData.GetTagName(), s, m), false)
+		return nil
+	}
 
-			if !resp {
-				os.Exit(0)
-			}
+	/**
+	----------------------
+	|   Install Logic    |
+	----------------------
+	**/
+
+	fmt.Println("Extracting files...")
+
+	// The archive is always hashed so the install manifest can record exactly what was installed.
+	digest := sha256.New()
+	var installed []string
+	if local || cached {
+		installed, err = core.InstallArchive(archivePath, installDir, archiveWriter(digest, verifier), verify)
+	} else {
+		installed, err = core.DownloadAndInstall(src, tar, archivePath, installDir, archiveWriter(digest, verifier), verify)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recordInstall(installed, src.String(), release.TagName, tar.Name, digest)
+
+	/**
+	----------------------
+	| Post-Install Logic |
+	----------------------
+	**/
+
+	fmt.Printf("%s has been successfully installed!\nLocation: %s\n", release.TagName, installDir)
+
+	return installed, nil
+}
+
+/*
+installArchive installs a runner from a local archive or from an archive at an arbitrary URL, rather than from a source.
+Arguments:
+
+	cmd<*cobra.Command>: The install command.
+	installDir<string>: The directory to install the runner to.
+	file<string>: The path to a local archive, or an empty string.
+	url<string>: The URL of an archive, or an empty string.
+*/
+func installArchive(cmd *cobra.Command, installDir, file, url string) {
+	if file != "" && url != "" {
+		fmt.Println("Only one of the --file and --url flags can be used at a time.")
+		os.Exit(1)
+	}
+
+	checksumFlag, _ := cmd.Flags().GetString("checksum")
+	sumFileFlag, _ := cmd.Flags().GetString("sum-file")
+	if checksumFlag != "" && sumFileFlag != "" {
+		fmt.Println("Only one of the --checksum and --sum-file flags can be used at a time.")
+		os.Exit(1)
+	}
+
+	tmp, err := core.GetUserTemp()
+	core.CheckError(err)
+
+	// Work out the name of the runner from the name of the archive.
+	archiveName := filepath.Base(file)
+	if url != "" {
+		archiveName = path.Base(strings.SplitN(url, "?", 2)[0])
+	}
+
+	tag, ok := core.TrimArchiveExtension(archiveName)
+	if !ok {
+		fmt.Println(archiveName + " is not a supported archive, expected one of: " + strings.Join(core.GetArchiveExtensions(), ", "))
+		os.Exit(1)
+	}
+
+	estimate := "unknown size"
+	if file != "" {
+		file = core.UsePath(file, false)
+		info, err := os.Stat(file)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
 
-		/**
-		----------------------
-		|   Download Logic   |
-		----------------------
-		**/
+		s, m := core.HumanReadableBytes(info.Size())
+		estimate = fmt.Sprintf("%v%s", s, m)
+	}
 
-		// Fetch valid assets from the release.
-		tar, sum, err := core.GetValidAssets(tagData)
-		core.CheckError(err)
+	confirmInstall(installDir, tag, estimate)
+
+	// Use the archive from the download cache (which is keyed by the URL) if it has been downloaded before.
+	cached := false
+	if url != "" {
+		if noCache, _ := cmd.Flags().GetBool("no-cache"); !noCache {
+			file, cached = core.GetCachedArchive(url, tag, archiveName)
+		}
 
-		if sum == nil && !viper.GetBool("app.force") {
-			fmt.Println("No checksum file was found for this release, skipping checksum verification.")
+		if cached {
+			fmt.Println("Using " + archiveName + " from the download cache.")
+		} else {
+			file = core.GetArchiveDownloadPath(url, tag, archiveName)
 		}
+	}
 
-		// Download the assets to the temp directory.
-		tmp, err := core.GetUserTemp()
+	// The size of an archive at a URL isn't known until the download starts, at which point the space is checked before anything is written.
+	if url == "" || cached {
+		installSize, err := core.GetInstallSize(file)
 		core.CheckError(err)
+		exitOnInstallError(checkFreeSpace(core.SpaceRequirement{Path: installDir, Size: installSize}))
+	}
+
+	// Get the checksum to verify the archive against, downloading the checksum file first if needed.
+	var verifier *core.ChecksumVerifier
+	switch {
+	case checksumFlag != "":
+		verifier, err = core.NewDigestVerifier(checksumFlag)
+		core.CheckError(err)
+	case sumFileFlag != "":
+		if strings.HasPrefix(sumFileFlag, "http://") || strings.HasPrefix(sumFileFlag, "https://") {
+			sumPath := tmp + path.Base(strings.SplitN(sumFileFlag, "?", 2)[0])
+			_, err = core.DownloadFile(sumPath, sumFileFlag)
+			core.CheckError(err)
+			sumFileFlag = sumPath
+		}
 
-		// Download the tarball.
-		_, err = core.DownloadFile(tmp+tar.GetName(), tar.GetBrowserDownloadURL())
+		verifier, err = core.NewChecksumFileVerifier(core.UsePath(sumFileFlag, false), archiveName)
 		core.CheckError(err)
+	default:
+		fmt.Println("No checksum was given, skipping checksum verification.")
+	}
+
+	verify := func() error {
+		if verifier != nil {
+			if err := handleChecksumResult(verifier.Result(), estimate); err != nil {
+				return err
+			}
+		}
 
-		/**
-		----------------------
-		|   Checksum Logic   |
-		----------------------
-		**/
+		if url != "" && !cached {
+			if _, err := core.CacheArchive(url, tag, archiveName, file); err != nil {
+				core.Debug("Unable to add " + archiveName + " to the download cache: " + err.Error())
+			}
+		}
 
-		// If it exists, download the checksum file and verify it against the downloaded tarball.
-		if sum != nil {
-			_, err = core.DownloadFile(tmp+sum.GetName(), sum.GetBrowserDownloadURL())
-			core.CheckError(err)
+		return nil
+	}
 
-			match, err := core.MatchChecksum(tmp+tar.GetName(), tmp+sum.GetName())
-			forceSum := viper.GetBool("app.force")
+	fmt.Println("Extracting files...")
 
-			core.CheckError(err)
+	digest := sha256.New()
+	var installed []string
+	if url != "" && !cached {
+		installed, err = core.DownloadURLAndInstall(url, file, installDir, archiveWriter(digest, verifier), verify)
+	} else {
+		installed, err = core.InstallArchive(file, installDir, archiveWriter(digest, verifier), verify)
+	}
+	exitOnInstallError(err)
 
-			// If the checksums don't match and force sum is enabled, abort.
-			if !match && viper.GetBool("app.force") {
-				fmt.Println("Checksums do not match, aborting install.")
-				os.Exit(1)
-			}
+	// Archives installed from outside of a source are recorded by their URL or absolute path.
+	origin := url
+	if origin == "" {
+		origin, _ = filepath.Abs(file)
+	}
+	recordInstall(installed, origin, tag, archiveName, digest)
 
-			// If the checksums don't match and force sum is disabled, prompt the user to continue unless -y flag is set.
-			if !match && !forceSum && yesFlag != "true" {
-				resp := core.Prompt(fmt.Sprintf("Checksums do not match, continue? [Est. %v%s] (y/N) ", s, m), false)
+	fmt.Printf("%s has been successfully installed!\nLocation: %s\n", tag, installDir)
+}
 
-				if !resp {
-					os.Exit(0)
-				}
+/*
+checkFreeSpace tells the user if there isn't enough free space for the install.
+Arguments:
 
-			} else if !match && !forceSum && yesFlag == "true" {
-				// -y flag is set, warn the user that the checksums don't match.
-				fmt.Println("Warning! Checksums do not match, continuing without verification due to -y flag.")
-			}
+	requirements<...core.SpaceRequirement>: The space needed in each directory.
 
-			// Everything checks out, continue with the install.
-			if match {
-				fmt.Println("Checksums verified successfully.")
+Returns:
+
+	error: errInsufficientSpace if the install won't fit.
+*/
+func checkFreeSpace(requirements ...core.SpaceRequirement) error {
+	if err := core.CheckFreeSpace(requirements...); err != nil {
+		fmt.Println("Unable to install: " + err.Error() + ".")
+		return errInsufficientSpace
+	}
+
+	return nil
+}
+
+/*
+confirmInstall asks the user to confirm the install, or to overwrite the existing installation of the same version.
+The existing installation is only replaced once the new one has been fully extracted. The prompts are skipped if the -y flag is set.
+Arguments:
+
+	installDir<string>: The directory the runner will be installed to.
+	tag<string>: The version that will be installed.
+	estimate<string>: The human readable estimated download size.
+*/
+func confirmInstall(installDir, tag, estimate string) {
+	yesFlag := RootCmd.Flag("yes").Value.String()
+
+	// Check if the directory exists already, meaning we're trying to install a version that's already installed.
+	if folderInfo, err := os.Stat(installDir + tag); err == nil && folderInfo.IsDir() {
+		// Prompt the user for to overwrite the existing version, skipped if -y flag is set.
+		if yesFlag != "true" {
+			resp := core.Prompt(fmt.Sprintf("Looks like %s is already installed, overwrite? [Est. %s] (y/N) ", tag, estimate), false)
+
+			if !resp {
+				os.Exit(0)
 			}
 		}
+	} else if yesFlag != "true" {
+		// Prompt the user to confirm the install, skipped if -y flag is set.
+		resp := core.Prompt(fmt.Sprintf("Are you sure you want to install %s? [Est. %s] (y/N) ", tag, estimate), false)
 
-		/**
-		----------------------
-		|   Install Logic    |
-		----------------------
-		**/
+		if !resp {
+			os.Exit(0)
+		}
+	}
+}
 
-		fmt.Println("Extracting files...")
+/*
+handleChecksumResult decides whether the install can continue after a checksum comparison.
+On a mismatch the --force flag (app.force) aborts the install, the -y flag warns and continues, and otherwise the user is asked whether to continue.
+Weak algorithms (sha1 and md5) can't prove that an archive wasn't tampered with, so their results are only warned about.
+Arguments:
 
-		err = core.ExtractTar(tmp+tar.GetName(), installDir)
-		core.CheckError(err)
+	result<*core.ChecksumResult>: The outcome of the checksum comparison.
+	estimate<string>: The human readable estimated download size.
 
-		/**
-		----------------------
-		| Post-Install Logic |
-		----------------------
-		**/
+Returns:
 
-		fmt.Printf("%s has been successfully installed!\nLocation: %s\n", tagData.GetTagName(), installDir)
-	},
+	error: errInstallCancelled or errVerificationFailed if the install should not go ahead.
+*/
+func handleChecksumResult(result *core.ChecksumResult, estimate string) error {
+	yesFlag := RootCmd.Flag("yes").Value.String()
+	forceSum := viper.GetBool("app.force")
+
+	if result.Weak {
+		if result.Match {
+			fmt.Printf("Warning! Checksums match, but %s only detects corrupted downloads and not tampering.\n", result.Algorithm)
+		} else {
+			fmt.Printf("Warning! Checksums do not match, continuing as %s checksums are not trusted for verification.\n", result.Algorithm)
+		}
+		return nil
+	}
+
+	// Everything checks out, continue with the install.
+	if result.Match {
+		fmt.Printf("Checksums verified successfully (%s).\n", result.Algorithm)
+		return nil
+	}
+
+	switch {
+	case forceSum:
+		// --force is set, never install an archive that failed its checksum.
+		fmt.Println("Checksums do not match, aborting install.")
+		return errVerificationFailed
+	case yesFlag != "true":
+		// Prompt the user to continue.
+		resp := core.Prompt(fmt.Sprintf("Checksums do not match, continue? [Est. %s] (y/N) ", estimate), false)
+
+		if !resp {
+			return errInstallCancelled
+		}
+	default:
+		// -y flag is set, warn the user that the checksums don't match.
+		fmt.Println("Warning! Checksums do not match, continuing without verification due to -y flag.")
+	}
+
+	return nil
+}
+
+/*
+verifySignature checks that a release from a source with trusted keys is signed by one of them.
+The signature can either be of the archive itself, or of the checksum file that verified the archive.
+A missing signature can be overridden with --force (app.force), but a bad signature can never be overridden.
+Arguments:
+
+	src<core.Source>: The source the release is from.
+	policy<*core.TrustPolicy>: The trust policy of the source.
+	assets<[]*core.Asset>: The assets of the release.
+	tmp<string>: The directory the assets are downloaded to.
+	archivePath<string>: The path to the downloaded runner archive.
+	tar<*core.Asset>: The runner archive.
+	sum<*core.Asset>: The checksum file, or nil.
+	sumVerified<bool>: Whether the archive matched the checksum file.
+
+Returns:
+
+	error: errVerificationFailed if the install should not go ahead, or an error if a signature couldn't be downloaded.
+*/
+func verifySignature(src core.Source, policy *core.TrustPolicy, assets []*core.Asset, tmp, archivePath string, tar, sum *core.Asset, sumVerified bool) error {
+	signed, signedPath, signatures := tar, archivePath, core.GetSignatureAssets(assets, tar)
+	if len(signatures) == 0 && sum != nil && sumVerified {
+		signed, signedPath, signatures = sum, tmp+sum.Name, core.GetSignatureAssets(assets, sum)
+	}
+
+	if len(signatures) == 0 {
+		if !viper.GetBool("app.force") {
+			fmt.Println("This source requires signed releases, but no signature was found for " + tar.Name + ", aborting install. Use --force to install it anyway.")
+			return errVerificationFailed
+		}
+
+		fmt.Println("Warning! No signature was found for " + tar.Name + ", continuing without verification due to --force.")
+		return nil
+	}
+
+	var paths []string
+	for _, signature := range signatures {
+		if _, err := core.DownloadAsset(tmp+signature.Name, src, signature); err != nil {
+			return err
+		}
+		paths = append(paths, tmp+signature.Name)
+	}
+
+	if err := policy.VerifySignatures(signedPath, paths); err != nil {
+		fmt.Println("Signature verification failed for " + signed.Name + ", refusing to install. This cannot be overridden with -y or --force.")
+		fmt.Println(err)
+		return errVerificationFailed
+	}
+
+	fmt.Println("Signature verified successfully (" + signed.Name + ").")
+	return nil
+}
+
+/*
+archiveWriter returns a writer for the archive to be hashed with, which writes to the digest and the verifier if there is a checksum to verify.
+Arguments:
+
+	digest<hash.Hash>: The digest recorded in the install manifest.
+	verifier<*core.ChecksumVerifier>: The verifier, or nil.
+
+Returns:
+
+	io.Writer: The writer.
+*/
+func archiveWriter(digest hash.Hash, verifier *core.ChecksumVerifier) io.Writer {
+	if verifier == nil {
+		return digest
+	}
+
+	return io.MultiWriter(digest, verifier)
+}
+
+/*
+recordInstall adds the installed runners to the install manifest. The runners are already in place by this point,
+so failing to record them is only warned about.
+Arguments:
+
+	paths<[]string>: The paths of the installed runners.
+	source<string>: Where the archive came from.
+	tag<string>: The version that was installed.
+	asset<string>: The file name of the archive.
+	digest<hash.Hash>: The sha256 digest of the archive.
+*/
+func recordInstall(paths []string, source, tag, asset string, digest hash.Hash) {
+	checksum := "sha256:" + hex.EncodeToString(digest.Sum(nil))
+	for _, path := range paths {
+		err := core.RecordInstall(&core.InstalledRunner{
+			Source:   source,
+			Tag:      tag,
+			Asset:    asset,
+			Checksum: checksum,
+			Path:     path,
+		})
+		if err != nil {
+			fmt.Println("Warning! Unable to record the install of " + filepath.Base(path) + ": " + err.Error())
+		}
+	}
+}
+
+/*
+exitOnInstallError exits if the install failed, quietly if the user chose not to continue, if verification failed or if
+there wasn't enough space (the reason has already been shown).
+Arguments:
+
+	err<error>: The error returned by the install, or nil.
+*/
+func exitOnInstallError(err error) {
+	var spaceErr *core.InsufficientSpaceError
+	switch {
+	case err == nil:
+		return
+	case errors.Is(err, errInstallCancelled):
+		os.Exit(0)
+	case errors.Is(err, errVerificationFailed), errors.Is(err, errInsufficientSpace):
+		os.Exit(1)
+	case errors.As(err, &spaceErr):
+		// Archives without a known size are only checked once the download starts.
+		fmt.Println("Unable to install: " + spaceErr.Error() + ".")
+		os.Exit(1)
+	default:
+		core.CheckError(err)
+	}
 }
 
 func init() {
 	RootCmd.AddCommand(installCmd)
 
 	// Register the command flags.
-	installCmd.Flags().BoolP("force", "f", false, "Force installation (ignoring missing or failed checksums)")
-	installCmd.Flags().IntP("source", "s", 0, "Specify the source to install from.")
+	installCmd.Flags().BoolP("force", "f", false, "Abort if the checksum doesn't match and install even if a required signature is missing (bad signatures are always refused)")
+	installCmd.Flags().StringP("source", "s", "", "Specify the source to install from, by index or name.")
+	installCmd.Flags().String("asset", "", "The name, glob or re: prefixed regular expression of the runner archive to install, for releases with several.")
+	installCmd.Flags().String("file", "", "Install from a local archive instead of a source.")
+	installCmd.Flags().String("url", "", "Install from an archive at the given URL instead of a source.")
+	installCmd.Flags().String("checksum", "", "The checksum to verify a --file or --url archive against, the algorithm is picked from its length or an algorithm: prefix.")
+	installCmd.Flags().Bool("no-cache", false, "Download the archive again even if it is in the download cache.")
+	installCmd.Flags().String("sum-file", "", "The path or URL of a checksum file (eg. .sha512sum or .sha256sum) to verify a --file or --url archive against.")
 
 	// Bind the flags to the viper config.
 	viper.BindPFlag("app.force", installCmd.Flags().Lookup("force"))
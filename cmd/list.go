@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/Blooym/proto/core"
 	"github.com/olekukonko/tablewriter"
@@ -43,6 +44,11 @@ var listCmd = &cobra.Command{
 		table.SetHeader([]string{"Version", "Size", "Installed"})
 		var totalSize int64
 		for _, d := range dir {
+			// Hidden entries aren't runners, eg. the work directory of an install that is in progress.
+			if strings.HasPrefix(d.Name(), ".") {
+				continue
+			}
+
 			size, err := core.GetDirSize(getDir + d.Name())
 
 			// Something went wrong getting the size of the directory.
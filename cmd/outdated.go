@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Blooym/proto/core"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// outdatedRunner is the newest runner installed from a source in a location, compared against the source's latest release.
+type outdatedRunner struct {
+	Location  string `json:"location"`
+	Source    string `json:"source"`
+	Installed string `json:"installed"`
+	Latest    string `json:"latest"`
+	AgeDays   *int   `json:"age_days"`
+	Outdated  bool   `json:"outdated"`
+	Command   string `json:"command,omitempty"`
+}
+
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "Show which installed runners have a newer release.",
+	Long: `Check every custom location, or only the directory given with --dir, for runners that have a newer release available from their source.
+Runners are matched to their source using the record Proto keeps of every install, runners installed before that are matched by their directory name being a release tag.
+The age is how many days ago the installed release was published. Exits with a status of 1 if anything is out of date, for use in scripts.`,
+	Example: `proto outdated
+proto outdated --dir steam --json`,
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		jsonFlag, _ := cmd.Flags().GetBool("json")
+
+		var runners []*outdatedRunner
+		for _, dir := range getOperatingDirs(cmd) {
+			installs, err := core.FindSourceInstalls(dir)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+
+			for _, source := range installs {
+				if source.Err != nil {
+					fmt.Fprintf(os.Stderr, "Unable to check %s for updates: %v\n", source.Source.String(), source.Err)
+					continue
+				}
+
+				if source.Latest() == nil {
+					continue
+				}
+
+				runners = append(runners, newOutdatedRunner(dir, source))
+			}
+		}
+
+		outdated := 0
+		for _, runner := range runners {
+			if runner.Outdated {
+				outdated++
+			}
+		}
+
+		if jsonFlag {
+			if runners == nil {
+				runners = []*outdatedRunner{}
+			}
+
+			contents, err := json.MarshalIndent(runners, "", "  ")
+			core.CheckError(err)
+			fmt.Println(string(contents))
+		} else if len(runners) == 0 {
+			fmt.Println("No runners from any of your sources were found.")
+		} else {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Location", "Source", "Installed", "Latest", "Age (Days)", "Install Command"})
+			table.SetAutoWrapText(false)
+			for _, runner := range runners {
+				age := "?"
+				if runner.AgeDays != nil {
+					age = strconv.Itoa(*runner.AgeDays)
+				}
+
+				command := "Up to date"
+				if runner.Outdated {
+					command = runner.Command
+				}
+
+				table.Append([]string{runner.Location, runner.Source, runner.Installed, runner.Latest, age, command})
+			}
+			table.Render()
+
+			if outdated == 0 {
+				fmt.Println("Everything is up to date.")
+			} else {
+				fmt.Printf("%d runner(s) are out of date, run 'proto upgrade' to install the latest releases.\n", outdated)
+			}
+		}
+
+		if outdated > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+/*
+newOutdatedRunner compares the newest runner installed from a source against the source's latest release.
+Arguments:
+
+	dir<string>: The install directory.
+	installs<*core.SourceInstalls>: The runners installed from the source.
+
+Returns:
+
+	*outdatedRunner: The comparison.
+*/
+func newOutdatedRunner(dir string, installs *core.SourceInstalls) *outdatedRunner {
+	installed := installs.Runners[0]
+	latest := installs.Latest()
+
+	runner := &outdatedRunner{
+		Location:  dir,
+		Source:    installs.Source.String(),
+		Installed: installed.Tag,
+		Latest:    latest.TagName,
+		Outdated:  !installs.Has(latest.TagName),
+	}
+
+	// Releases older than the ones fetched for matching are looked up on their own.
+	release := findRelease(installs.Releases, installed.Tag)
+	if release == nil {
+		if data, err := core.GetReleaseData(installs.SourceIndex, installed.Tag); err == nil {
+			release = data
+		} else {
+			core.Debug("newOutdatedRunner: Unable to find the release for " + installed.Tag + ": " + err.Error())
+		}
+	}
+
+	if release != nil && !release.PublishedAt.IsZero() {
+		age := int(time.Since(release.PublishedAt).Hours() / 24)
+		runner.AgeDays = &age
+	}
+
+	if runner.Outdated {
+		runner.Command = fmt.Sprintf("proto install %s --source %s --dir %s", shellQuote(latest.TagName), shellQuote(getSourceFlag(installs.SourceIndex)), shellQuote(getDirFlag(dir)))
+	}
+
+	return runner
+}
+
+/*
+findRelease returns the release with the given tag.
+Arguments:
+
+	releases<[]*core.Release>: The releases to search.
+	tag<string>: The tag of the release.
+
+Returns:
+
+	*core.Release: The release, or nil if it isn't in the list.
+*/
+func findRelease(releases []*core.Release, tag string) *core.Release {
+	for _, release := range releases {
+		if release.TagName == tag {
+			return release
+		}
+	}
+
+	return nil
+}
+
+/*
+getSourceFlag returns the value of the --source flag that selects the given source, its name if it has one or otherwise its position.
+Arguments:
+
+	index<int>: The index of the source.
+
+Returns:
+
+	string: The value for the --source flag.
+*/
+func getSourceFlag(index int) string {
+	if name := core.GetSourceName(viper.GetStringSlice("app.sources")[index]); name != "" {
+		return name
+	}
+
+	return strconv.Itoa(index + 1)
+}
+
+/*
+getDirFlag returns the value of the --dir flag for the given directory, the keyword of its custom location if it has one.
+Arguments:
+
+	dir<string>: The directory.
+
+Returns:
+
+	string: The value for the --dir flag.
+*/
+func getDirFlag(dir string) string {
+	for keyword, location := range viper.GetStringMapString("app.customlocations") {
+		if core.UsePath(location, true) == dir {
+			return keyword
+		}
+	}
+
+	return dir
+}
+
+/*
+shellQuote quotes the given argument for a POSIX shell if it contains anything other than safe characters.
+Arguments:
+
+	arg<string>: The argument.
+
+Returns:
+
+	string: The quoted argument.
+*/
+func shellQuote(arg string) string {
+	safe := arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=+@%,", r))
+	}) == -1
+
+	if safe {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func init() {
+	RootCmd.AddCommand(outdatedCmd)
+
+	outdatedCmd.Flags().Bool("json", false, "Print the report as JSON.")
+}
@@ -7,53 +7,57 @@ import (
 	"github.com/Blooym/proto/core"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var releasesCmd = &cobra.Command{
-	Use:     "releases",
-	Short:   "Show all available releases from the runner source.",
-	Example: `proto releases --limit 5`,
+	Use:   "releases",
+	Short: "Show all available releases from the runner source.",
+	Example: `proto releases --limit 5
+proto releases --limit 50 --page 2
+proto releases --all`,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		// If there are multiple sources, ask the user which one to use or use the flag.
-		var source int
-		sourceFlag, _ := cmd.Flags().GetInt("source")
-		if sourceFlag > 0 {
-			sourceLen := len(viper.GetStringSlice("app.sources"))
+		sourceFlag, _ := cmd.Flags().GetString("source")
+		source := core.SelectSourceIndex(sourceFlag)
 
-			if sourceFlag-1 >= sourceLen {
-				fmt.Println("There is no source at index", sourceFlag, "you only have", sourceLen, "sources.")
-				os.Exit(1)
-			}
+		limit, _ := cmd.Flags().GetInt("limit")
+		page, _ := cmd.Flags().GetInt("page")
+		all, _ := cmd.Flags().GetBool("all")
+
+		if page < 1 {
+			fmt.Println("The page must be 1 or greater.")
+			os.Exit(1)
+		}
 
-			source = sourceFlag - 1
-		} else {
-			source = core.PromptSourceIndex()
+		// Work out which releases are on the requested page, fetching only as many as needed to reach it.
+		start, end := (page-1)*limit, page*limit
+		if all {
+			start, end = 0, 0
+		} else if limit <= 0 {
+			fmt.Println("The limit must be 1 or greater, use --all to show every release.")
+			os.Exit(1)
 		}
 
 		// Get the releases from the backend.
-		releases, err := core.GetReleases(source)
+		releases, err := core.GetReleases(source, end)
 		core.CheckError(err)
 
+		if start >= len(releases) {
+			fmt.Println("There are no releases on page", page, "of this source.")
+			os.Exit(0)
+		}
+
 		// Create a table to display the releases.
 		table := tablewriter.NewWriter(os.Stdout)
 		table.SetHeader([]string{"Tag", "Released On", "Info Command"})
-		limit, _ := cmd.Flags().GetInt("limit")
-
-		// Loop through the releases and add them to the table up to the limit.
-		for _, release := range releases {
-
-			if limit > 0 {
-				limit--
-			} else {
-				break
-			}
 
+		// Loop through the releases on the page and add them to the table.
+		for _, release := range releases[start:] {
 			table.Append([]string{
-				release.GetTagName(),
-				release.GetPublishedAt().Format("2006-01-02"),
-				fmt.Sprintf("proto info %s -s %d", release.GetTagName(), source+1),
+				release.TagName,
+				release.PublishedAt.Format("2006-01-02"),
+				fmt.Sprintf("proto info %s -s %d", release.TagName, source+1),
 			})
 		}
 
@@ -66,6 +70,8 @@ func init() {
 	RootCmd.AddCommand(releasesCmd)
 
 	// Register command flags
-	releasesCmd.Flags().IntP("limit", "l", 5, "Limit the number of releases to show.")
-	releasesCmd.Flags().IntP("source", "s", 0, "The source to use.")
+	releasesCmd.Flags().IntP("limit", "l", 5, "Limit the number of releases to show per page.")
+	releasesCmd.Flags().IntP("page", "p", 1, "The page of releases to show, with --limit releases per page.")
+	releasesCmd.Flags().BoolP("all", "a", false, "Show every release (up to 1000) instead of a single page.")
+	releasesCmd.Flags().StringP("source", "s", "", "The index or name of the source to use.")
 }
@@ -29,9 +29,11 @@ func init() {
 	RootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose output")
 	RootCmd.PersistentFlags().BoolP("yes", "y", false, "Skip all confirmation prompts")
 	RootCmd.PersistentFlags().StringP("dir", "d", "", "The directory to operate in")
+	RootCmd.PersistentFlags().Bool("offline", false, "Only use cached release data, without making any network requests")
 
 	// Register flags to config
 	viper.BindPFlag("cli.verbose", RootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("cache.offline", RootCmd.PersistentFlags().Lookup("offline"))
 }
 
 // Initialize proto configuration file
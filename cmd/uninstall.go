@@ -49,6 +49,11 @@ var uninstallCmd = &cobra.Command{
 		err := os.RemoveAll(getDir)
 		core.CheckError(err)
 
+		// The runner is already gone, so failing to update the install manifest is only warned about.
+		if err := core.RemoveInstall(getDir); err != nil {
+			fmt.Println("Warning! Unable to remove " + args[0] + " from the install manifest: " + err.Error())
+		}
+
 		fmt.Printf("Successfully uninstalled %s from %s\n", args[0], filepath.Dir(getDir))
 	},
 }
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Blooym/proto/core"
+	"github.com/spf13/cobra"
+)
+
+// upgrade is a runner that will be installed to bring a source's runners in a directory up to date.
+type upgrade struct {
+	dir      string
+	installs *core.SourceInstalls
+}
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Install the latest release everywhere an older one is installed.",
+	Long: `Install the latest release of every source that has runners installed in your custom locations, or only in the directory given with --dir.
+Runners are matched to their source using the record Proto keeps of every install, runners installed before that are matched by their directory name being a release tag.
+Previous versions are kept unless --replace or --keep is used, runners that were only matched by their directory name are never removed without asking, even with -y.`,
+	Example: `proto upgrade
+proto upgrade --dir steam --replace
+proto upgrade --keep 2`,
+	Args: cobra.ExactArgs(0),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		core.DeleteUserTemp()
+	},
+	PostRun: func(cmd *cobra.Command, args []string) {
+		core.DeleteUserTemp()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+
+		// Prevent the program from having another long-running process
+		lock := core.HandleLock()
+		defer lock.Unlock()
+
+		replace, _ := cmd.Flags().GetBool("replace")
+		keep, _ := cmd.Flags().GetInt("keep")
+		if replace && cmd.Flags().Changed("keep") {
+			fmt.Println("Only one of the --replace and --keep flags can be used at a time.")
+			os.Exit(1)
+		}
+
+		if cmd.Flags().Changed("keep") && keep < 1 {
+			fmt.Println("The --keep flag must be at least 1, as the latest release is always kept.")
+			os.Exit(1)
+		}
+
+		if replace {
+			keep = 1
+		}
+
+		// Work out what needs upgrading before changing anything.
+		var upgrades []*upgrade
+		for _, dir := range getOperatingDirs(cmd) {
+			installs, err := core.FindSourceInstalls(dir)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			for _, source := range installs {
+				if source.Err != nil {
+					fmt.Printf("Unable to check %s for updates: %v\n", source.Source.String(), source.Err)
+					continue
+				}
+
+				if source.Latest() == nil || source.Has(source.Latest().TagName) {
+					continue
+				}
+
+				upgrades = append(upgrades, &upgrade{dir: dir, installs: source})
+			}
+		}
+
+		if len(upgrades) == 0 {
+			fmt.Println("Everything is already up to date.")
+			return
+		}
+
+		fmt.Println("The following runners will be upgraded:")
+		for _, u := range upgrades {
+			fmt.Printf("  %s: %s -> %s (%s)\n", u.dir, u.installs.Runners[0].Tag, u.installs.Latest().TagName, u.installs.Source.String())
+		}
+
+		if RootCmd.Flag("yes").Value.String() != "true" {
+			if !core.Prompt(fmt.Sprintf("Are you sure you want to upgrade %d runner(s)? (y/N) ", len(upgrades)), false) {
+				os.Exit(0)
+			}
+		}
+
+		// A failed upgrade doesn't stop the others, the failures are listed at the end instead.
+		var failed []string
+		for _, u := range upgrades {
+			fmt.Printf("\nUpgrading %s in %s to %s...\n", u.installs.Runners[0].Tag, u.dir, u.installs.Latest().TagName)
+
+			// Each install gets a fresh temp directory.
+			core.DeleteUserTemp()
+			installed, err := installRelease(cmd, u.installs.SourceIndex, u.installs.Source, u.installs.Latest(), u.dir, false)
+			if err != nil {
+				if !errors.Is(err, errInstallCancelled) && !errors.Is(err, errVerificationFailed) && !errors.Is(err, errInsufficientSpace) {
+					fmt.Println("Unable to install " + u.installs.Latest().TagName + ": " + err.Error())
+				}
+
+				failed = append(failed, fmt.Sprintf("%s in %s", u.installs.Latest().TagName, u.dir))
+				continue
+			}
+
+			if keep > 0 {
+				removePreviousVersions(u.installs, installed, keep)
+			}
+		}
+
+		if len(failed) > 0 {
+			fmt.Printf("\n%d of %d upgrade(s) failed:\n", len(failed), len(upgrades))
+			for _, f := range failed {
+				fmt.Println("  " + f)
+			}
+
+			core.DeleteUserTemp()
+			os.Exit(1)
+		}
+	},
+}
+
+/*
+getOperatingDirs returns the directory given with the --dir flag, or every custom location that exists if it wasn't given.
+Arguments:
+
+	cmd<*cobra.Command>: The command being run.
+
+Returns:
+
+	[]string: The directories to operate in.
+*/
+func getOperatingDirs(cmd *cobra.Command) []string {
+	if dir := cmd.Flag("dir").Value.String(); dir != "" {
+		return []string{core.UsePath(core.GetCustomLocation(dir), true)}
+	}
+
+	var dirs []string
+	seen := map[string]bool{}
+	for _, location := range core.GetCustomLocations() {
+		dir := core.UsePath(location, true)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() || seen[filepath.Clean(dir)] {
+			continue
+		}
+
+		seen[filepath.Clean(dir)] = true
+		dirs = append(dirs, dir)
+	}
+
+	sort.Strings(dirs)
+	return dirs
+}
+
+/*
+removePreviousVersions uninstalls the oldest runners from a source until only the given number are left, counting the new install.
+Only runners in the install manifest are removed automatically. Runners that were only matched by their directory name could be
+something else entirely, so they are listed and only removed if the user confirms it, even with the -y flag.
+Arguments:
+
+	installs<*core.SourceInstalls>: The runners that were installed from the source before the upgrade, newest first.
+	installed<[]string>: The paths of the new install, which are never removed.
+	keep<int>: How many runners to keep.
+*/
+func removePreviousVersions(installs *core.SourceInstalls, installed []string, keep int) {
+	isNew := map[string]bool{}
+	for _, path := range installed {
+		isNew[filepath.Clean(path)] = true
+	}
+
+	kept := 1
+	var untracked []*core.InstalledRunner
+	for _, runner := range installs.Runners {
+		if isNew[filepath.Clean(runner.Path)] {
+			continue
+		}
+
+		if kept < keep {
+			kept++
+			continue
+		}
+
+		if !runner.Tracked() {
+			untracked = append(untracked, runner)
+			continue
+		}
+
+		removeRunner(runner)
+	}
+
+	if len(untracked) == 0 {
+		return
+	}
+
+	fmt.Println("The following runners are not in the install manifest, they were only matched to " + installs.Source.String() + " by their directory name:")
+	for _, runner := range untracked {
+		fmt.Println("  " + runner.Path)
+	}
+
+	if !core.Prompt(fmt.Sprintf("Remove these %d runner(s) as well? (y/N) ", len(untracked)), false) {
+		fmt.Println("Keeping them.")
+		return
+	}
+
+	for _, runner := range untracked {
+		removeRunner(runner)
+	}
+}
+
+/*
+removeRunner uninstalls a runner and removes it from the install manifest, warning about anything that goes wrong.
+Arguments:
+
+	runner<*core.InstalledRunner>: The runner to remove.
+*/
+func removeRunner(runner *core.InstalledRunner) {
+	if err := os.RemoveAll(runner.Path); err != nil {
+		fmt.Printf("Unable to remove %s: %v\n", runner.Tag, err)
+		return
+	}
+
+	if err := core.RemoveInstall(runner.Path); err != nil {
+		fmt.Println("Warning! Unable to remove " + runner.Tag + " from the install manifest: " + err.Error())
+	}
+
+	fmt.Printf("Removed %s from %s\n", runner.Tag, filepath.Dir(runner.Path))
+}
+
+func init() {
+	RootCmd.AddCommand(upgradeCmd)
+
+	upgradeCmd.Flags().Bool("replace", false, "Uninstall the previous versions once the latest release has been installed.")
+	upgradeCmd.Flags().Int("keep", 0, "Only keep this many versions from each source once the latest release has been installed, including the latest.")
+}
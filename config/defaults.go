@@ -34,6 +34,17 @@ func SetDefaults() {
 		"lutrisflatpak": "~/.var/app/net.lutris.Lutris/data/lutris/runners/wine",
 	})
 
+	// Configure cache defaults
+	viper.SetDefault("cache.ttl", "10m")
+
+	// Configure download defaults
+	viper.SetDefault("download.connecttimeout", "30s")
+	viper.SetDefault("download.readtimeout", "60s")
+	viper.SetDefault("download.retries", "5")
+
+	// Configure auth defaults
+	viper.SetDefault("auth.githubcredentials", configDir+"/proto/github_token")
+
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			os.MkdirAll(configDir+"/proto", os.ModePerm)
@@ -4,43 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
-	github "github.com/google/go-github/v44/github"
 	"github.com/spf13/viper"
 )
 
-/*
-FormatRepo takes a source index and returns the owner and repo.
-Arguments:
-
-	entryIndex<int>: The index of the source to get the owner and repo from.
-
-Example:
-
-	owner, repo := FormatRepo(0)
-	fmt.Println(owner) // Blooym
-	fmt.Println(repo) // proto
-
-Returns:
-
-	string: The owner of the repo.
-	string: The name of the repo.
-*/
-func FormatRepo(entryIndex int) (string, string) {
-
-	sources := viper.GetStringSlice("app.sources")
-
-	if len(sources) == 0 {
-		fmt.Println("No sources have been configured. Please add a source with `proto config sources add <owner/repo>`.")
-		os.Exit(1)
-	}
-
-	split := strings.Split(sources[entryIndex], "/")
-
-	return split[0], split[1]
-}
-
 /*
 PromptSourceIndex asks the user which source they want to use if they do not manually specify one.
 Example:
@@ -90,32 +59,82 @@ func PromptSourceIndex() int {
 }
 
 /*
-GetReleases returns all of the releases for the specified source index.
+SelectSourceIndex returns the index of the source chosen with the --source flag, which can either be the position of the source (starting at 1) or its name.
+If no source was given, the user is asked which one to use.
 Arguments:
 
-	entryIndex<int>: The index of the source to get the owner and repo from.
+	flag<string>: The value of the --source flag.
 
 Example:
 
-	releases, err := GetReleases(0)
+	index := SelectSourceIndex("mirror")
+	fmt.Println(index) // 2
 
 Returns:
 
-	[]*github.RepositoryRelease: A list of all of the releases for the specified source index.
-	error: Any errors that occur.
+	int: The index of the selected source.
 */
-func GetReleases(entryIndex int) ([]*github.RepositoryRelease, error) {
-	owner, repo := FormatRepo(entryIndex)
-	client := github.NewClient(nil)
+func SelectSourceIndex(flag string) int {
+	sources := viper.GetStringSlice("app.sources")
+
+	if flag == "" || flag == "0" {
+		return PromptSourceIndex()
+	}
+
+	// The flag is the position of the source in the list.
+	if position, err := strconv.Atoi(flag); err == nil {
+		if position < 1 || position > len(sources) {
+			fmt.Println("There is no source at index", position, "you only have", len(sources), "sources.")
+			os.Exit(1)
+		}
+
+		return position - 1
+	}
+
+	// The flag is the name of the source, or the source entry itself.
+	for i, source := range sources {
+		if GetSourceName(source) == flag || source == flag {
+			Debug("SelectSourceIndex: Matched source " + flag + " to index " + fmt.Sprintf("%d", i))
+			return i
+		}
+	}
+
+	fmt.Println("There is no source named", flag+".", "Run 'proto config sources list' to see your sources.")
+	os.Exit(1)
+
+	// Return 0 to satisfy the compiler.
+	return 0
+}
+
+/*
+GetReleases returns the newest releases for the specified source index, only fetching as many pages from the source as needed.
+Arguments:
+
+	entryIndex<int>: The index of the source to get the releases from.
+	limit<int>: The maximum number of releases to return, 0 or less for every release (capped at 1000).
 
-	releases, _, err := client.Repositories.ListReleases(context.Background(), owner, repo, nil)
+Example:
+
+	releases, err := GetReleases(0, 10)
 
-	Debug("GetReleases: Found " + fmt.Sprintf("%d", len(releases)) + " releases for " + owner + "/" + repo)
+Returns:
+
+	[]*Release: A list of the releases for the specified source index.
+	error: Any errors that occur.
+*/
+func GetReleases(entryIndex int, limit int) ([]*Release, error) {
+	source, err := GetSource(entryIndex)
+	if err != nil {
+		return nil, err
+	}
 
+	releases, err := source.ListReleases(context.Background(), limit)
 	if err != nil {
 		return nil, err
 	}
 
+	Debug("GetReleases: Found " + fmt.Sprintf("%d", len(releases)) + " releases for " + source.String())
+
 	return releases, nil
 }
 
@@ -123,7 +142,7 @@ func GetReleases(entryIndex int) ([]*github.RepositoryRelease, error) {
 GetReleaseData returns the release data for the specified source index and tag.
 Arguments:
 
-	entryIndex<int>: The index of the source to get the owner and repo from.
+	entryIndex<int>: The index of the source to get the release from.
 	tag<string>: The tag of the release to get the data for.
 
 Example:
@@ -132,20 +151,22 @@ Example:
 
 Returns:
 
-	*github.RepositoryRelease: The release data for the specified source index and tag.
+	*Release: The release data for the specified source index and tag.
 	error: Any errors that occur.
 */
-func GetReleaseData(entryIndex int, tag string) (*github.RepositoryRelease, error) {
-	owner, repo := FormatRepo(entryIndex)
-	client := github.NewClient(nil)
-	release, _, err := client.Repositories.GetReleaseByTag(context.Background(), owner, repo, tag)
+func GetReleaseData(entryIndex int, tag string) (*Release, error) {
+	source, err := GetSource(entryIndex)
+	if err != nil {
+		return nil, err
+	}
 
-	Debug("GetReleaseData: Looking for: " + owner + "/" + repo + "/" + tag)
+	Debug("GetReleaseData: Looking for: " + source.String() + "/" + tag)
+	release, err := source.GetRelease(context.Background(), tag)
 	if err != nil {
 		return nil, err
 	}
 
-	Debug("GetReleaseData: Found release " + release.GetTagName())
+	Debug("GetReleaseData: Found release " + release.TagName)
 	return release, nil
 }
 
@@ -153,7 +174,7 @@ func GetReleaseData(entryIndex int, tag string) (*github.RepositoryRelease, erro
 GetTotalAssetSize returns the total size of all of the assets in the specified release.
 Arguments:
 
-	assets<[]*github.ReleaseAsset>: The assets to get the total size of.
+	assets<[]*Asset>: The assets to get the total size of.
 
 Example:
 
@@ -164,79 +185,119 @@ Returns:
 
 	int64: The total size of all of the assets in the specified release.
 */
-func GetTotalAssetSize(assets []*github.ReleaseAsset) int64 {
-	var size int
+func GetTotalAssetSize(assets []*Asset) int64 {
+	var size int64
 
 	// Loop through all of the assets and add their sizes together.
 	for _, asset := range assets {
-		if strings.HasSuffix(asset.GetName(), ".tar.gz") {
-			size += int(asset.GetSize())
-		}
-
-		if strings.HasSuffix(asset.GetName(), ".tar.xz") {
-			size += int(asset.GetSize())
+		if IsArchive(asset.Name) {
+			size += asset.Size
 		}
 
-		if strings.HasSuffix(asset.GetName(), ".sha512sum") {
-			size += asset.GetSize()
+		if IsChecksumFile(asset.Name) {
+			size += asset.Size
 		}
 	}
 
-	return int64(size)
+	return size
 }
 
 /*
-GetValidAssets returns a tar file and a sha512sum file from the specified release.
+GetValidAssets returns a runner archive and a checksum file from the specified assets, using the filter to decide which assets can be used.
+If several runner archives are allowed by the filter, the user is asked to pick one, or an error listing them is returned if prompting is disabled.
 Arguments:
 
-	release<*github.RepositoryRelease>: The release to get the assets from.
+	assets<[]*Asset>: The assets to pick from.
+	filter<*AssetFilter>: The asset filter of the source, or nil to allow every asset.
+	prompt<bool>: Whether the user can be asked to pick between several runner archives.
 
 Example:
 
-	assets := GetValidAssets(release.Assets)
-	fmt.Println(assets) // [tar.xz, sha512sum]
+	tar, sum, err := GetValidAssets(release.Assets, filter, true)
+	fmt.Println(tar.Name, sum.Name) // runner.tar.xz runner.sha512sum
 
 Returns:
 
-	[]*github.ReleaseAsset: A list of the tar file and the sha512sum file.
+	*Asset: The runner archive.
+	*Asset: The checksum file, or nil if there is none.
+	error: Any errors that occur.
 */
-func GetValidAssets(release *github.RepositoryRelease) (*github.ReleaseAsset, *github.ReleaseAsset, error) {
-	var runnerTar *github.ReleaseAsset
-	var runnerSum *github.ReleaseAsset
+func GetValidAssets(assets []*Asset, filter *AssetFilter, prompt bool) (*Asset, *Asset, error) {
+	if filter == nil {
+		filter = &AssetFilter{}
+	}
 
-	for _, asset := range release.Assets {
+	var runnerTars []*Asset
+	var runnerSums []*Asset
 
-		Debug("GetValidAssets: Validating asset: " + asset.GetName())
+	for _, asset := range assets {
 
-		// Once we have both assets, we don't need to keep looking.
-		if runnerTar != nil && runnerSum != nil {
-			Debug("GetValidAssets: Found both assets, finishing search.")
-			break
-		}
+		Debug("GetValidAssets: Validating asset: " + asset.Name)
 
 		// Find the files needed for installing the runner.
-		// Any tar file is supported, but it is recommended to use the .tar.xz format for better compression.
-		if strings.HasSuffix(asset.GetName(), ".tar.gz") {
-			Debug("GetValidAssets: Found a valid tar.gz asset.")
-			runnerTar = asset
-		} else if strings.HasSuffix(asset.GetName(), ".tar.xz") {
-			Debug("GetValidAssets: Found a valid tar.xz asset.")
-			runnerTar = asset
-		} else if strings.HasSuffix(asset.GetName(), ".sha512sum") {
-			Debug("GetValidAssets: Found a valid sha512sum asset.")
-			runnerSum = asset
+		// Any supported archive format works, but it is recommended to use the .tar.xz format for better compression.
+		if filter.IsArchive(asset) {
+			Debug("GetValidAssets: Found a valid archive asset.")
+			runnerTars = append(runnerTars, asset)
+		} else if filter.IsChecksum(asset) {
+			Debug("GetValidAssets: Found a valid checksum asset.")
+			runnerSums = append(runnerSums, asset)
+		}
+	}
+
+	var runnerTar *Asset
+	switch {
+	case len(runnerTars) == 0: // There was no archive found for the release.
+		return nil, nil, fmt.Errorf("unable to find a runner archive, expected one of: %s", strings.Join(GetArchiveExtensions(), ", "))
+	case len(runnerTars) == 1:
+		runnerTar = runnerTars[0]
+	case prompt:
+		runnerTar = PromptAsset(runnerTars)
+	default:
+		names := make([]string, 0, len(runnerTars))
+		for _, asset := range runnerTars {
+			names = append(names, asset.Name)
+		}
+		return nil, nil, fmt.Errorf("multiple runner archives found (%s), use the --asset flag to pick one", strings.Join(names, ", "))
+	}
+
+	return runnerTar, matchChecksumAsset(runnerTar, runnerSums), nil
+}
+
+/*
+matchChecksumAsset returns the checksum file belonging to the given runner archive.
+Checksums named after the archive or its tag are preferred, followed by aggregate checksum files covering every asset
+(eg. "SHA256SUMS"), otherwise a lone checksum file is assumed to belong to the archive.
+Arguments:
+
+	archive<*Asset>: The runner archive.
+	sums<[]*Asset>: The checksum files to choose from.
+
+Returns:
+
+	*Asset: The checksum file, or nil if none of them belong to the archive.
+*/
+func matchChecksumAsset(archive *Asset, sums []*Asset) *Asset {
+	tag, _ := TrimArchiveExtension(archive.Name)
+	for _, sum := range sums {
+		if strings.HasPrefix(sum.Name, archive.Name+".") || strings.HasPrefix(sum.Name, tag+".") {
+			return sum
+		}
+	}
+
+	for _, sum := range sums {
+		if IsAggregateChecksumFile(sum.Name) {
+			return sum
 		}
 	}
 
-	// There was no tarball found for the release.
-	if runnerTar == nil {
-		return nil, nil, fmt.Errorf("unable to find a runner tarball")
+	if len(sums) == 1 {
+		return sums[0]
 	}
 
-	// There was no valid checksum found for the release.
-	if runnerSum == nil {
-		return runnerTar, nil, nil
+	if len(sums) > 1 {
+		Debug("matchChecksumAsset: None of the checksum files belong to " + archive.Name)
 	}
 
-	return runnerTar, runnerSum, nil
+	return nil
 }
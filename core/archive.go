@@ -0,0 +1,670 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// archiveFormat is an archive format that runners can be installed from.
+type archiveFormat struct {
+	extension string
+	extract   func(ctx context.Context, archivePath, extractPath string) error
+
+	// stream extracts the archive while it is being read (eg. downloaded), or is nil for formats that need the whole file first.
+	stream func(ctx context.Context, r io.Reader, extractPath string) error
+
+	// expansion is roughly how many times larger the extracted files are than the archive, used to estimate the space an install needs.
+	expansion float64
+}
+
+// archiveFormats are the archive formats that Proto can install runners from, matched by file extension.
+// This is the only list of supported formats: finding, sizing and extracting release assets all go through it.
+// Zip files keep their index at the end, so unlike tarballs they can't be extracted until they have been completely downloaded.
+var archiveFormats = []archiveFormat{
+	{".tar.gz", extractTar, extractTarReader, 3},
+	{".tgz", extractTar, extractTarReader, 3},
+	{".tar.xz", extractTar, extractTarReader, 4},
+	{".tar.zst", extractTar, extractTarReader, 3.5},
+	{".tar.bz2", extractTar, extractTarReader, 3.5},
+	{".zip", extractZip, nil, 3},
+}
+
+/*
+getArchiveFormat returns the archive format of the given file name.
+Arguments:
+
+	name<string>: The file name.
+
+Returns:
+
+	*archiveFormat: The archive format, or nil if the file is not a supported archive.
+*/
+func getArchiveFormat(name string) *archiveFormat {
+	for i, format := range archiveFormats {
+		if strings.HasSuffix(strings.ToLower(name), format.extension) {
+			return &archiveFormats[i]
+		}
+	}
+
+	return nil
+}
+
+/*
+IsArchive returns whether the given file name is an archive that runners can be installed from.
+Arguments:
+
+	name<string>: The file name.
+
+Example:
+
+	ok := IsArchive("dxvk-2.3.tar.gz")
+	fmt.Println(ok) // true
+
+Returns:
+
+	bool: Whether the file is a supported archive.
+*/
+func IsArchive(name string) bool {
+	return getArchiveFormat(name) != nil
+}
+
+/*
+TrimArchiveExtension removes a supported archive extension from the given file name.
+Arguments:
+
+	name<string>: The file name.
+
+Example:
+
+	tag, ok := TrimArchiveExtension("GE-Proton8-25.tar.gz")
+	fmt.Println(tag, ok) // GE-Proton8-25 true
+
+Returns:
+
+	string: The file name without the extension.
+	bool: Whether the file name had a supported archive extension.
+*/
+func TrimArchiveExtension(name string) (string, bool) {
+	format := getArchiveFormat(name)
+	if format == nil {
+		return name, false
+	}
+
+	return name[:len(name)-len(format.extension)], true
+}
+
+/*
+GetArchiveExtensions returns the file extensions of every supported archive format.
+Example:
+
+	extensions := GetArchiveExtensions()
+	fmt.Println(extensions) // [.tar.gz .tgz .tar.xz .tar.zst .tar.bz2 .zip]
+
+Returns:
+
+	[]string: The supported file extensions.
+*/
+func GetArchiveExtensions() []string {
+	extensions := make([]string, 0, len(archiveFormats))
+	for _, format := range archiveFormats {
+		extensions = append(extensions, format.extension)
+	}
+
+	return extensions
+}
+
+// tarCompressions maps the magic bytes at the start of a compressed tarball to the decompressor for it.
+var tarCompressions = []struct {
+	name  string
+	magic []byte
+	open  func(r io.Reader) (io.ReadCloser, error)
+}{
+	{"gzip", []byte{0x1f, 0x8b}, func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	}},
+	{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, func(r io.Reader) (io.ReadCloser, error) {
+		reader, err := xz.NewReader(r)
+		return io.NopCloser(reader), err
+	}},
+	{"bzip2", []byte{'B', 'Z', 'h'}, func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	}},
+	{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}, func(r io.Reader) (io.ReadCloser, error) {
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}},
+}
+
+/*
+extractTar extracts the given tar file to the given path, stopping as soon as the context is cancelled.
+Arguments:
+
+	ctx<context.Context>: The context of the extraction.
+	tarPath<string>: The path to the tar file.
+	extractPath<string>: The path to extract the tar file to.
+
+Returns:
+
+	error: An error if one occurs.
+*/
+func extractTar(ctx context.Context, tarPath, extractPath string) error {
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	Debug("extractTar: Extracting " + tarPath + " to " + extractPath)
+
+	return extractTarReader(ctx, file, extractPath)
+}
+
+/*
+extractTarReader extracts the tarball read from the given stream to the given path, stopping as soon as the context is cancelled.
+The compression is detected from the start of the stream.
+Arguments:
+
+	ctx<context.Context>: The context of the extraction.
+	r<io.Reader>: The tarball stream.
+	extractPath<string>: The path to extract the tarball to.
+
+Returns:
+
+	error: An error if one occurs.
+*/
+func extractTarReader(ctx context.Context, r io.Reader, extractPath string) error {
+	reader, err := decompressTar(bufio.NewReader(r))
+	if err != nil {
+		return fmt.Errorf("unable to read the tarball: %w", err)
+	}
+
+	defer reader.Close()
+
+	return extractTarStream(&contextReader{ctx: ctx, r: reader}, extractPath)
+}
+
+// contextReader is an io.Reader that stops reading once its context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
+
+/*
+decompressTar wraps the given tarball stream in the decompressor matching its magic bytes, uncompressed tarballs are returned as-is.
+Arguments:
+
+	r<*bufio.Reader>: The tarball stream.
+
+Returns:
+
+	io.ReadCloser: The decompressed tarball stream.
+	error: An error if one occurs.
+*/
+func decompressTar(r *bufio.Reader) (io.ReadCloser, error) {
+	header, err := r.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	for _, compression := range tarCompressions {
+		if bytes.HasPrefix(header, compression.magic) {
+			Debug("decompressTar: Detected " + compression.name + " compression")
+			return compression.open(r)
+		}
+	}
+
+	return io.NopCloser(r), nil
+}
+
+/*
+extractTarStream extracts every entry of the given uncompressed tar stream into the given directory.
+Arguments:
+
+	r<io.Reader>: The uncompressed tar stream.
+	extractPath<string>: The directory to extract into.
+
+Returns:
+
+	error: An error naming the entry that could not be extracted, if one occurs.
+*/
+func extractTarStream(r io.Reader, extractPath string) error {
+	reader := tar.NewReader(r)
+
+	return extractEntries(extractPath, func() (*tar.Header, io.Reader, error) {
+		header, err := reader.Next()
+		if err != nil && err != io.EOF {
+			return nil, nil, fmt.Errorf("unable to read the tarball: %w", err)
+		}
+
+		return header, reader, err
+	})
+}
+
+/*
+extractZip extracts the given zip file to the given path, stopping as soon as the context is cancelled.
+Arguments:
+
+	ctx<context.Context>: The context of the extraction.
+	zipPath<string>: The path to the zip file.
+	extractPath<string>: The path to extract the zip file to.
+
+Returns:
+
+	error: An error if one occurs.
+*/
+func extractZip(ctx context.Context, zipPath, extractPath string) error {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", filepath.Base(zipPath), err)
+	}
+
+	defer reader.Close()
+
+	Debug("extractZip: Extracting " + zipPath + " to " + extractPath)
+
+	var current io.ReadCloser
+	defer func() {
+		if current != nil {
+			current.Close()
+		}
+	}()
+
+	// Zip entries are converted to tar headers, so they go through exactly the same checks as tarballs.
+	files := reader.File
+	return extractEntries(extractPath, func() (*tar.Header, io.Reader, error) {
+		if current != nil {
+			current.Close()
+			current = nil
+		}
+
+		if len(files) == 0 {
+			return nil, nil, io.EOF
+		}
+
+		file := files[0]
+		files = files[1:]
+
+		contents, err := file.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("entry %s: %w", file.Name, err)
+		}
+		current = contents
+
+		// Symlinks are stored in zip files as entries containing the link target.
+		var linkname string
+		if file.Mode()&os.ModeSymlink != 0 {
+			target, err := io.ReadAll(io.LimitReader(contents, 4096))
+			if err != nil {
+				return nil, nil, fmt.Errorf("entry %s: %w", file.Name, err)
+			}
+			linkname = string(target)
+		}
+
+		header, err := tar.FileInfoHeader(file.FileInfo(), linkname)
+		if err != nil {
+			return nil, nil, fmt.Errorf("entry %s: %w", file.Name, err)
+		}
+
+		header.Name = file.Name
+		header.ModTime = file.Modified
+
+		return header, &contextReader{ctx: ctx, r: contents}, nil
+	})
+}
+
+/*
+extractEntries extracts every entry returned by the given function into the given directory, until it returns io.EOF.
+Arguments:
+
+	extractPath<string>: The directory to extract into.
+	next<func() (*tar.Header, io.Reader, error)>: Returns the header and contents of the next entry.
+
+Returns:
+
+	error: An error naming the entry that could not be extracted, if one occurs.
+*/
+func extractEntries(extractPath string, next func() (*tar.Header, io.Reader, error)) error {
+	if err := os.MkdirAll(extractPath, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Directory permissions and times are applied last, so that read-only directories can still be filled
+	// and extracting their contents doesn't change their modification time.
+	type dirMeta struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var dirs []dirMeta
+
+	for {
+		header, contents, err := next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		target, err := extractEntry(contents, header, extractPath)
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			dirs = append(dirs, dirMeta{target, header.FileInfo().Mode().Perm(), header.ModTime})
+		}
+	}
+
+	// Apply directory metadata deepest first, so that setting a parent's time isn't undone by its children.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return fmt.Errorf("entry %s: %w", dirs[i].path, err)
+		}
+
+		os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime)
+	}
+
+	return nil
+}
+
+/*
+extractEntry safely extracts a single tar entry into the given directory, refusing anything that could escape it or is otherwise unsafe.
+Arguments:
+
+	r<io.Reader>: The tar reader positioned at the entry.
+	header<*tar.Header>: The header of the entry.
+	extractPath<string>: The directory being extracted into.
+
+Returns:
+
+	string: The path the entry was extracted to.
+	error: An error naming the entry if it was blocked or could not be extracted.
+*/
+func extractEntry(r io.Reader, header *tar.Header, extractPath string) (string, error) {
+	target, err := safeEntryPath(extractPath, header.Name)
+	if err != nil {
+		return "", err
+	}
+
+	// Never follow a symlink extracted from the archive, as it could lead anywhere.
+	if err := checkNoSymlinks(extractPath, target, header.Typeflag == tar.TypeDir); err != nil {
+		return "", &UnsafeEntryError{Entry: header.Name, Reason: err.Error()}
+	}
+
+	mode := header.FileInfo().Mode()
+	if mode&(os.ModeSetuid|os.ModeSetgid) != 0 {
+		return "", &UnsafeEntryError{Entry: header.Name, Reason: "setuid and setgid permissions are not allowed"}
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		err = os.MkdirAll(target, 0700)
+	case tar.TypeReg, tar.TypeRegA:
+		err = extractTarFile(r, target, mode.Perm(), header.ModTime)
+	case tar.TypeSymlink:
+		if err := checkSymlinkTarget(extractPath, target, header.Linkname); err != nil {
+			return "", &UnsafeEntryError{Entry: header.Name, Reason: err.Error()}
+		}
+
+		err = replaceWith(target, func() error { return os.Symlink(header.Linkname, target) })
+	case tar.TypeLink:
+		source, linkErr := safeEntryPath(extractPath, header.Linkname)
+		if linkErr != nil {
+			return "", &UnsafeEntryError{Entry: header.Name, Reason: "hardlink to " + header.Linkname + " escapes the install directory"}
+		}
+
+		if err := checkNoSymlinks(extractPath, source, false); err != nil {
+			return "", &UnsafeEntryError{Entry: header.Name, Reason: err.Error()}
+		}
+
+		// Linking to a symlink would re-create it at a path where its target may resolve outside the install directory.
+		if info, err := os.Lstat(source); err == nil && !info.Mode().IsRegular() {
+			return "", &UnsafeEntryError{Entry: header.Name, Reason: "hardlink to " + header.Linkname + " is not a regular file"}
+		}
+
+		err = replaceWith(target, func() error { return os.Link(source, target) })
+	case tar.TypeChar, tar.TypeBlock:
+		return "", &UnsafeEntryError{Entry: header.Name, Reason: "device nodes are not allowed"}
+	case tar.TypeFifo:
+		return "", &UnsafeEntryError{Entry: header.Name, Reason: "named pipes are not allowed"}
+	default:
+		Debug(fmt.Sprintf("ExtractTar: Skipping entry %s with unsupported type %q", header.Name, header.Typeflag))
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("entry %s: %w", header.Name, err)
+	}
+
+	return target, nil
+}
+
+// UnsafeEntryError is returned when an archive entry is blocked from being extracted, such as one that would escape the install directory.
+type UnsafeEntryError struct {
+	Entry  string
+	Reason string
+}
+
+func (e *UnsafeEntryError) Error() string {
+	return fmt.Sprintf("blocked unsafe archive entry %q: %s", e.Entry, e.Reason)
+}
+
+/*
+safeEntryPath returns where an archive entry should be extracted to, refusing absolute names and names that escape the directory.
+Arguments:
+
+	extractPath<string>: The directory being extracted into.
+	name<string>: The name of the entry.
+
+Returns:
+
+	string: The path to extract the entry to.
+	error: An *UnsafeEntryError if the name is not allowed.
+*/
+func safeEntryPath(extractPath, name string) (string, error) {
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(name) {
+		return "", &UnsafeEntryError{Entry: name, Reason: "absolute paths are not allowed"}
+	}
+
+	target := filepath.Join(extractPath, name)
+	if !isWithin(extractPath, target) {
+		return "", &UnsafeEntryError{Entry: name, Reason: "path escapes the install directory"}
+	}
+
+	return target, nil
+}
+
+/*
+checkSymlinkTarget makes sure a symlink extracted to the given path only points to somewhere inside of the directory being extracted into.
+Arguments:
+
+	extractPath<string>: The directory being extracted into.
+	target<string>: The path the symlink is being extracted to.
+	linkname<string>: Where the symlink points.
+
+Returns:
+
+	error: An error describing why the symlink is not allowed.
+*/
+func checkSymlinkTarget(extractPath, target, linkname string) error {
+	if filepath.IsAbs(linkname) {
+		return fmt.Errorf("symlink to absolute path %s is not allowed", linkname)
+	}
+
+	// The kernel resolves ".." from wherever the path so far points, which may be through another symlink from the archive
+	// (eg. "d -> ." then "l -> d/../escaped"), so ".." is only allowed at the start where it walks up through real directories.
+	descended := false
+	for _, part := range strings.Split(linkname, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if descended {
+				return fmt.Errorf("symlink to %s is not allowed, \"..\" can only be used at the start of a symlink", linkname)
+			}
+		default:
+			descended = true
+		}
+	}
+
+	if !isWithin(extractPath, filepath.Join(filepath.Dir(target), linkname)) {
+		return fmt.Errorf("symlink to %s escapes the install directory", linkname)
+	}
+
+	return nil
+}
+
+/*
+checkNoSymlinks makes sure none of the existing directories between the extraction directory and the given path are symlinks.
+Arguments:
+
+	extractPath<string>: The directory being extracted into.
+	target<string>: The path to check.
+	includeTarget<bool>: Whether the path itself must not be a symlink either.
+
+Returns:
+
+	error: An error naming the symlink that would be followed.
+*/
+func checkNoSymlinks(extractPath, target string, includeTarget bool) error {
+	rel, err := filepath.Rel(extractPath, target)
+	if err != nil || rel == "." {
+		return err
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	if !includeTarget {
+		parts = parts[:len(parts)-1]
+	}
+
+	current := extractPath
+	for _, part := range parts {
+		current = filepath.Join(current, part)
+
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			rel, _ := filepath.Rel(extractPath, current)
+			return fmt.Errorf("path passes through the symlink %s", rel)
+		}
+	}
+
+	return nil
+}
+
+/*
+isWithin reports whether the given path is the given directory or is inside of it.
+Arguments:
+
+	dir<string>: The directory.
+	path<string>: The path to check.
+
+Returns:
+
+	bool: Whether the path is inside of the directory.
+*/
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+/*
+extractTarFile writes the contents of the current tar entry to a regular file.
+Arguments:
+
+	r<io.Reader>: The tar reader positioned at the entry.
+	target<string>: The path to write the file to.
+	mode<os.FileMode>: The permissions of the file.
+	modTime<time.Time>: The modification time of the file.
+
+Returns:
+
+	error: An error if one occurs.
+*/
+func extractTarFile(r io.Reader, target string, mode os.FileMode, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	// Never write through an existing symlink or hardlink.
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// Permissions are set after writing to avoid the umask and so read-only files can be written.
+	if err := os.Chmod(target, mode); err != nil {
+		return err
+	}
+
+	return os.Chtimes(target, modTime, modTime)
+}
+
+/*
+replaceWith creates a link at the given path using the given function, replacing anything that was already there.
+Arguments:
+
+	target<string>: The path to create the link at.
+	create<func() error>: The function that creates the link.
+
+Returns:
+
+	error: An error if one occurs.
+*/
+func replaceWith(target string, create func() error) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return create()
+}
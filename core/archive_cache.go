@@ -0,0 +1,429 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// The archive cache keeps every downloaded runner archive so that installing the same release again (eg. to both Steam and Lutris)
+// doesn't download it again. Archives are stored by the sha256 digest of their contents under archiveCacheBlobDir, the index
+// maps a source, tag and asset name to a digest, and downloads that haven't finished yet are kept in archiveCacheDownloadDir
+// so they can be resumed by the next install.
+const (
+	archiveCacheIndexName   = "index.json"
+	archiveCacheBlobDir     = "sha256"
+	archiveCacheDownloadDir = "downloads"
+)
+
+// CachedArchive is a single runner archive stored in the archive cache.
+type CachedArchive struct {
+	Source  string    `json:"source"`
+	Tag     string    `json:"tag"`
+	Asset   string    `json:"asset"`
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	AddedAt time.Time `json:"added_at"`
+	UsedAt  time.Time `json:"used_at"`
+}
+
+/*
+GetArchiveCacheDir returns the directory downloaded runner archives are cached in.
+Example:
+
+	dir := GetArchiveCacheDir()
+	fmt.Println(dir) // $HOME/.cache/proto/archives
+
+Returns:
+
+	string: The path to the archive cache directory.
+*/
+func GetArchiveCacheDir() string {
+	cacheDir, _ := os.UserCacheDir()
+	return filepath.Join(cacheDir, "proto", "archives")
+}
+
+/*
+Path returns where the contents of the cached archive are stored, the file keeps the asset's name so that checksum files listing it still match.
+Returns:
+
+	string: The path to the archive.
+*/
+func (a *CachedArchive) Path() string {
+	return filepath.Join(GetArchiveCacheDir(), archiveCacheBlobDir, a.SHA256, a.Asset)
+}
+
+/*
+ListCachedArchives returns every archive in the archive cache, most recently used first.
+Example:
+
+	archives, err := ListCachedArchives()
+	fmt.Println(archives[0].Tag) // GE-Proton8-25
+
+Returns:
+
+	[]*CachedArchive: The cached archives.
+	error: Any errors that occur.
+*/
+func ListCachedArchives() ([]*CachedArchive, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(GetArchiveCacheDir(), archiveCacheIndexName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var archives []*CachedArchive
+	if err := json.Unmarshal(contents, &archives); err != nil {
+		return nil, fmt.Errorf("the archive cache index is corrupt, run 'proto cache clean' to reset it: %w", err)
+	}
+
+	sort.SliceStable(archives, func(i, j int) bool {
+		return archives[i].UsedAt.After(archives[j].UsedAt)
+	})
+
+	return archives, nil
+}
+
+/*
+GetCachedArchive looks up the given release asset in the archive cache. The archive is checked against its digest before
+being returned, anything that has gone missing or been modified is dropped from the cache.
+Arguments:
+
+	source<string>: The source the asset was published by (as returned by Source.String).
+	tag<string>: The tag of the release.
+	asset<string>: The name of the asset.
+
+Example:
+
+	path, ok := GetCachedArchive("GloriousEggroll/proton-ge-custom", "GE-Proton8-25", "GE-Proton8-25.tar.gz")
+
+Returns:
+
+	string: The path to the cached archive.
+	bool: Whether the asset was in the cache.
+*/
+func GetCachedArchive(source, tag, asset string) (string, bool) {
+	archives, err := ListCachedArchives()
+	if err != nil {
+		Debug("GetCachedArchive: " + err.Error())
+		return "", false
+	}
+
+	for i, archive := range archives {
+		if archive.Source != source || archive.Tag != tag || archive.Asset != asset {
+			continue
+		}
+
+		digest, err := fileSHA256(archive.Path())
+		if err != nil || digest != archive.SHA256 {
+			Debug("GetCachedArchive: Dropping missing or modified cache entry for " + asset)
+			removeCachedArchives(append(archives[:i:i], archives[i+1:]...), []*CachedArchive{archive})
+			return "", false
+		}
+
+		archive.UsedAt = time.Now()
+		if err := writeArchiveCacheIndex(archives); err != nil {
+			Debug("GetCachedArchive: " + err.Error())
+		}
+
+		Debug("GetCachedArchive: Found " + asset + " in the archive cache at " + archive.Path())
+		return archive.Path(), true
+	}
+
+	return "", false
+}
+
+/*
+GetArchiveDownloadPath returns where to download the given release asset to before it is added to the archive cache.
+The path is the same for every attempt, so an interrupted download is resumed by the next install.
+Arguments:
+
+	source<string>: The source the asset is published by (as returned by Source.String).
+	tag<string>: The tag of the release.
+	asset<string>: The name of the asset.
+
+Returns:
+
+	string: The path to download the asset to.
+*/
+func GetArchiveDownloadPath(source, tag, asset string) string {
+	key := sha256.Sum256([]byte(source + "\n" + tag + "\n" + asset))
+	return filepath.Join(GetArchiveCacheDir(), archiveCacheDownloadDir, fmt.Sprintf("%x", key[:8]), asset)
+}
+
+/*
+CacheArchive moves a downloaded release asset into the archive cache, archives with the same contents are only stored once.
+Arguments:
+
+	source<string>: The source the asset was published by (as returned by Source.String).
+	tag<string>: The tag of the release.
+	asset<string>: The name of the asset.
+	path<string>: The path the asset was downloaded to.
+
+Example:
+
+	path, err = CacheArchive("GloriousEggroll/proton-ge-custom", "GE-Proton8-25", "GE-Proton8-25.tar.gz", path)
+
+Returns:
+
+	string: The path to the archive in the cache.
+	error: Any errors that occur.
+*/
+func CacheArchive(source, tag, asset, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+
+	archive := &CachedArchive{
+		Source:  source,
+		Tag:     tag,
+		Asset:   asset,
+		SHA256:  digest,
+		Size:    info.Size(),
+		AddedAt: time.Now(),
+		UsedAt:  time.Now(),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(archive.Path()), 0700); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(path, archive.Path()); err != nil {
+		return "", err
+	}
+
+	os.Remove(filepath.Dir(path))
+
+	archives, err := ListCachedArchives()
+	if err != nil {
+		return "", err
+	}
+
+	// Replace any older entry for the same asset, eg. if the release was re-uploaded.
+	kept := archives[:0]
+	var replaced []*CachedArchive
+	for _, existing := range archives {
+		if existing.Source == source && existing.Tag == tag && existing.Asset == asset {
+			replaced = append(replaced, existing)
+			continue
+		}
+		kept = append(kept, existing)
+	}
+
+	kept = append(kept, archive)
+	if err := removeCachedArchives(kept, replaced); err != nil {
+		return "", err
+	}
+
+	Debug("CacheArchive: Added " + asset + " to the archive cache at " + archive.Path())
+
+	return archive.Path(), nil
+}
+
+/*
+CleanArchiveCache removes every archive from the archive cache, including unfinished downloads.
+Example:
+
+	freed, err := CleanArchiveCache()
+
+Returns:
+
+	int64: The number of bytes freed.
+	error: Any errors that occur.
+*/
+func CleanArchiveCache() (int64, error) {
+	size, err := GetDirSize(GetArchiveCacheDir())
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	if err := os.RemoveAll(GetArchiveCacheDir()); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+/*
+PruneArchiveCache shrinks the archive cache to at most the given size by removing the least recently used archives first.
+Unfinished downloads and files that are no longer listed in the cache index are always removed.
+Arguments:
+
+	maxSize<int64>: The maximum size of the cache in bytes.
+
+Example:
+
+	removed, freed, err := PruneArchiveCache(2 * 1024 * 1024 * 1024)
+
+Returns:
+
+	[]*CachedArchive: The archives that were removed.
+	int64: The number of bytes freed.
+	error: Any errors that occur.
+*/
+func PruneArchiveCache(maxSize int64) ([]*CachedArchive, int64, error) {
+	before, err := GetDirSize(GetArchiveCacheDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+
+	archives, err := ListCachedArchives()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := os.RemoveAll(filepath.Join(GetArchiveCacheDir(), archiveCacheDownloadDir)); err != nil {
+		return nil, 0, err
+	}
+
+	// Archives are sorted most recently used first, so keep them in order until the cache is full.
+	// Archives with the same contents share a file, which only counts towards the size once.
+	var kept, removed []*CachedArchive
+	var size int64
+	counted := map[string]bool{}
+	for _, archive := range archives {
+		if _, err := os.Stat(archive.Path()); err != nil {
+			continue
+		}
+
+		if counted[archive.Path()] {
+			kept = append(kept, archive)
+			continue
+		}
+
+		if size+archive.Size > maxSize {
+			removed = append(removed, archive)
+			continue
+		}
+
+		size += archive.Size
+		counted[archive.Path()] = true
+		kept = append(kept, archive)
+	}
+
+	if err := removeCachedArchives(kept, removed); err != nil {
+		return nil, 0, err
+	}
+
+	// Remove anything else that the index doesn't know about, such as archives left behind by an interrupted write.
+	blobs, _ := filepath.Glob(filepath.Join(GetArchiveCacheDir(), archiveCacheBlobDir, "*", "*"))
+	for _, blob := range blobs {
+		if !counted[blob] {
+			Debug("PruneArchiveCache: Removing unlisted file " + blob)
+			os.Remove(blob)
+			os.Remove(filepath.Dir(blob))
+		}
+	}
+
+	after, err := GetDirSize(GetArchiveCacheDir())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return removed, before - after, nil
+}
+
+/*
+removeCachedArchives writes the given archives as the new cache index, then deletes the files of the removed archives
+that aren't shared with any of the kept ones.
+Arguments:
+
+	kept<[]*CachedArchive>: The archives to keep.
+	removed<[]*CachedArchive>: The archives to remove.
+
+Returns:
+
+	error: Any errors that occur.
+*/
+func removeCachedArchives(kept, removed []*CachedArchive) error {
+	if err := writeArchiveCacheIndex(kept); err != nil {
+		return err
+	}
+
+	inUse := map[string]bool{}
+	for _, archive := range kept {
+		inUse[archive.Path()] = true
+	}
+
+	for _, archive := range removed {
+		if inUse[archive.Path()] {
+			continue
+		}
+
+		Debug("removeCachedArchives: Removing " + archive.Path())
+		if err := os.Remove(archive.Path()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		os.Remove(filepath.Dir(archive.Path()))
+	}
+
+	return nil
+}
+
+/*
+writeArchiveCacheIndex atomically replaces the archive cache index with the given archives.
+Arguments:
+
+	archives<[]*CachedArchive>: The archives in the cache.
+
+Returns:
+
+	error: Any errors that occur.
+*/
+func writeArchiveCacheIndex(archives []*CachedArchive) error {
+	if err := os.MkdirAll(GetArchiveCacheDir(), 0700); err != nil {
+		return err
+	}
+
+	contents, err := json.MarshalIndent(archives, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(GetArchiveCacheDir(), archiveCacheIndexName), contents)
+}
+
+/*
+fileSHA256 returns the hex encoded sha256 digest of the file at the given path, which is what cached archives are stored by.
+Arguments:
+
+	path<string>: The path to the file.
+
+Returns:
+
+	string: The digest of the file.
+	error: Any errors that occur.
+*/
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarEntry is a single entry of a tarball built by buildTar.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	contents string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+			Mode:     0755,
+			Size:     int64(len(entry.contents)),
+		}
+		if entry.typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+
+		if entry.typeflag == tar.TypeReg {
+			if _, err := writer.Write([]byte(entry.contents)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+func TestSafeEntryPath(t *testing.T) {
+	extractPath := "/install"
+
+	tests := []struct {
+		name    string
+		want    string
+		blocked bool
+	}{
+		{"GE-Proton8-25/proton", "/install/GE-Proton8-25/proton", false},
+		{"./GE-Proton8-25/", "/install/GE-Proton8-25", false},
+		{"a/../b", "/install/b", false},
+		{".", "/install", false},
+		{"../escaped", "", true},
+		{"a/../../escaped", "", true},
+		{"/etc/passwd", "", true},
+		{"..", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := safeEntryPath(extractPath, test.name)
+
+		var unsafe *UnsafeEntryError
+		if test.blocked {
+			if !errors.As(err, &unsafe) {
+				t.Errorf("safeEntryPath(%q) = %q, %v, want an UnsafeEntryError", test.name, got, err)
+			}
+			continue
+		}
+
+		if err != nil || got != test.want {
+			t.Errorf("safeEntryPath(%q) = %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestCheckSymlinkTarget(t *testing.T) {
+	extractPath := "/install"
+
+	tests := []struct {
+		target   string
+		linkname string
+		allowed  bool
+	}{
+		{"/install/runner/lib", "files/lib", true},
+		{"/install/runner/files/lib64", "../lib", true},
+		{"/install/runner/files/lib64", "../../other/lib", true},
+		{"/install/runner/current", ".", true},
+		{"/install/runner/lib", "../../escaped", false},
+		{"/install/lib", "../escaped", false},
+		{"/install/lib", "/usr/lib", false},
+		{"/install/l", "d/../escaped", false},
+		{"/install/l", "d/../../escaped", false},
+		{"/install/runner/l", "./d/../lib", false},
+	}
+
+	for _, test := range tests {
+		err := checkSymlinkTarget(extractPath, test.target, test.linkname)
+		if (err == nil) != test.allowed {
+			t.Errorf("checkSymlinkTarget(%q, %q) = %v, want allowed %v", test.target, test.linkname, err, test.allowed)
+		}
+	}
+}
+
+func TestExtractTarStreamBlocksSymlinkEscape(t *testing.T) {
+	tests := map[string][]tarEntry{
+		// "l" resolves through the symlink "d", so "d/.." is the parent of the extract directory rather than the directory itself.
+		"through an extracted symlink": {
+			{name: "d", typeflag: tar.TypeSymlink, linkname: "."},
+			{name: "l", typeflag: tar.TypeSymlink, linkname: "d/../escaped"},
+		},
+		"before the symlink it passes through": {
+			{name: "l", typeflag: tar.TypeSymlink, linkname: "d/../escaped"},
+			{name: "d", typeflag: tar.TypeSymlink, linkname: "."},
+		},
+		"out of the directory": {
+			{name: "l", typeflag: tar.TypeSymlink, linkname: "../escaped"},
+		},
+		"writing through a symlink": {
+			{name: "d", typeflag: tar.TypeSymlink, linkname: "."},
+			{name: "d/file", typeflag: tar.TypeReg, contents: "data"},
+		},
+		"hardlink out of the directory": {
+			{name: "l", typeflag: tar.TypeLink, linkname: "../escaped"},
+		},
+		"hardlink to a symlink": {
+			{name: "a/", typeflag: tar.TypeDir},
+			{name: "a/s", typeflag: tar.TypeSymlink, linkname: "../escaped"},
+			{name: "l", typeflag: tar.TypeLink, linkname: "a/s"},
+		},
+	}
+
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			extractPath := filepath.Join(root, "install")
+			if err := os.WriteFile(filepath.Join(root, "escaped"), []byte("secret"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := extractTarStream(buildTar(t, entries), extractPath)
+
+			var unsafe *UnsafeEntryError
+			if !errors.As(err, &unsafe) {
+				t.Fatalf("extractTarStream() = %v, want an UnsafeEntryError", err)
+			}
+
+			if contents, err := os.ReadFile(filepath.Join(extractPath, "l")); err == nil {
+				t.Fatalf("read %q outside of the extract directory through l", contents)
+			}
+		})
+	}
+}
+
+func TestExtractTarStream(t *testing.T) {
+	extractPath := filepath.Join(t.TempDir(), "install")
+
+	err := extractTarStream(buildTar(t, []tarEntry{
+		{name: "runner/", typeflag: tar.TypeDir},
+		{name: "runner/files/", typeflag: tar.TypeDir},
+		{name: "runner/files/lib", typeflag: tar.TypeReg, contents: "lib"},
+		{name: "runner/lib", typeflag: tar.TypeSymlink, linkname: "files/lib"},
+		{name: "runner/files/lib64", typeflag: tar.TypeSymlink, linkname: "../files/lib"},
+		{name: "runner/copy", typeflag: tar.TypeLink, linkname: "runner/files/lib"},
+	}), extractPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"runner/files/lib", "runner/lib", "runner/files/lib64", "runner/copy"} {
+		contents, err := os.ReadFile(filepath.Join(extractPath, name))
+		if err != nil || string(contents) != "lib" {
+			t.Errorf("reading %s = %q, %v, want %q", name, contents, err, "lib")
+		}
+	}
+}
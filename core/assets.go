@@ -0,0 +1,268 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// regexPatternPrefix marks an asset pattern as a regular expression rather than a glob.
+const regexPatternPrefix = "re:"
+
+// AssetFilter decides which assets of a release can be installed, using the include and exclude patterns of a source.
+// Patterns are globs matched against the asset name (eg. "*-debug.tar.gz"), or regular expressions when prefixed with "re:".
+type AssetFilter struct {
+	include    []*assetPattern
+	exclude    []*assetPattern
+	sumInclude []*assetPattern
+	sumExclude []*assetPattern
+	asset      *assetPattern
+}
+
+// assetPattern is a single compiled glob or regular expression pattern.
+type assetPattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+/*
+newAssetFilter creates an asset filter from the options of a source entry.
+Arguments:
+
+	options<url.Values>: The options of the source entry.
+
+Returns:
+
+	*AssetFilter: The asset filter.
+	error: An error if any of the patterns are invalid.
+*/
+func newAssetFilter(options url.Values) (*AssetFilter, error) {
+	filter := &AssetFilter{}
+
+	for option, target := range map[string]*[]*assetPattern{
+		"include":     &filter.include,
+		"exclude":     &filter.exclude,
+		"sum-include": &filter.sumInclude,
+		"sum-exclude": &filter.sumExclude,
+	} {
+		for _, raw := range options[option] {
+			pattern, err := compileAssetPattern(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s pattern %q: %w", option, raw, err)
+			}
+
+			*target = append(*target, pattern)
+		}
+	}
+
+	return filter, nil
+}
+
+/*
+GetAssetFilter returns the asset filter configured for the source at the specified source index.
+Arguments:
+
+	entryIndex<int>: The index of the source.
+
+Example:
+
+	filter, err := GetAssetFilter(0)
+
+Returns:
+
+	*AssetFilter: The asset filter of the source.
+	error: Any errors that occur.
+*/
+func GetAssetFilter(entryIndex int) (*AssetFilter, error) {
+	sources := viper.GetStringSlice("app.sources")
+	if entryIndex < 0 || entryIndex >= len(sources) {
+		return nil, fmt.Errorf("there is no source at index %d", entryIndex+1)
+	}
+
+	_, _, options, err := SplitSourceEntry(sources[entryIndex])
+	if err != nil {
+		return nil, err
+	}
+
+	return newAssetFilter(options)
+}
+
+/*
+WithAsset returns a copy of the filter that also requires runner archives to match the given pattern, as used by the --asset flag.
+Arguments:
+
+	raw<string>: A glob or "re:" prefixed regular expression, an exact asset name also works.
+
+Example:
+
+	filter, err = filter.WithAsset("*-debug.tar.gz")
+
+Returns:
+
+	*AssetFilter: The narrowed filter.
+	error: An error if the pattern is invalid.
+*/
+func (f *AssetFilter) WithAsset(raw string) (*AssetFilter, error) {
+	pattern, err := compileAssetPattern(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset pattern %q: %w", raw, err)
+	}
+
+	narrowed := *f
+	narrowed.asset = pattern
+	return &narrowed, nil
+}
+
+/*
+IsArchive returns whether the given asset is a runner archive allowed by the filter.
+Arguments:
+
+	asset<*Asset>: The asset to check.
+
+Returns:
+
+	bool: Whether the asset is an allowed runner archive.
+*/
+func (f *AssetFilter) IsArchive(asset *Asset) bool {
+	if !IsArchive(asset.Name) || (f.asset != nil && !f.asset.matches(asset.Name)) {
+		return false
+	}
+
+	return matchesPatterns(asset.Name, f.include, f.exclude)
+}
+
+/*
+IsChecksum returns whether the given asset is a checksum file allowed by the filter.
+Arguments:
+
+	asset<*Asset>: The asset to check.
+
+Returns:
+
+	bool: Whether the asset is an allowed checksum file.
+*/
+func (f *AssetFilter) IsChecksum(asset *Asset) bool {
+	if !IsChecksumFile(asset.Name) {
+		return false
+	}
+
+	return matchesPatterns(asset.Name, f.sumInclude, f.sumExclude)
+}
+
+/*
+matchesPatterns returns whether the name matches any of the include patterns (if there are any) and none of the exclude patterns.
+Arguments:
+
+	name<string>: The name to check.
+	include<[]*assetPattern>: The include patterns.
+	exclude<[]*assetPattern>: The exclude patterns.
+
+Returns:
+
+	bool: Whether the name is allowed.
+*/
+func matchesPatterns(name string, include, exclude []*assetPattern) bool {
+	for _, pattern := range exclude {
+		if pattern.matches(name) {
+			return false
+		}
+	}
+
+	if len(include) == 0 {
+		return true
+	}
+
+	for _, pattern := range include {
+		if pattern.matches(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+PromptAsset asks the user which of the given runner archives they want to install.
+Arguments:
+
+	assets<[]*Asset>: The runner archives to choose from.
+
+Example:
+
+	asset := PromptAsset(archives)
+	fmt.Println(asset.Name) // GE-Proton8-25.tar.gz
+
+Returns:
+
+	*Asset: The runner archive the user selected.
+*/
+func PromptAsset(assets []*Asset) *Asset {
+	var choice int
+
+	fmt.Println("\nMultiple runner archives found. Which one do you want to install?")
+	for i, asset := range assets {
+		s, m := HumanReadableBytes(asset.Size)
+		fmt.Printf("%d. %s [%v%s]\n", i+1, asset.Name, s, m)
+	}
+	fmt.Println("0. Cancel")
+	fmt.Print("Choice: ")
+	fmt.Scanf("%d", &choice)
+
+	// If the user cancels, exit.
+	if choice == 0 {
+		os.Exit(0)
+	}
+
+	// If the user selects an archive that doesn't exist, try again.
+	if choice < 1 || choice > len(assets) {
+		Debug("PromptAsset: User chose an invalid asset.")
+		return PromptAsset(assets)
+	}
+
+	fmt.Println("")
+	Debug("PromptAsset: User chose asset: " + assets[choice-1].Name)
+	return assets[choice-1]
+}
+
+/*
+compileAssetPattern compiles an asset pattern, which is a regular expression if prefixed with "re:" and a glob otherwise.
+Arguments:
+
+	raw<string>: The pattern.
+
+Returns:
+
+	*assetPattern: The compiled pattern.
+	error: An error if the pattern is invalid.
+*/
+func compileAssetPattern(raw string) (*assetPattern, error) {
+	if strings.HasPrefix(raw, regexPatternPrefix) {
+		regex, err := regexp.Compile(strings.TrimPrefix(raw, regexPatternPrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		return &assetPattern{regex: regex}, nil
+	}
+
+	// Check the glob is valid up front, rather than silently never matching.
+	if _, err := path.Match(raw, ""); err != nil {
+		return nil, err
+	}
+
+	return &assetPattern{glob: raw}, nil
+}
+
+func (p *assetPattern) matches(name string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+
+	matched, _ := path.Match(p.glob, name)
+	return matched
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// gitHubTokenEnvVars are the environment variables checked for a GitHub token, in order of priority.
+var gitHubTokenEnvVars = []string{"PROTO_GITHUB_TOKEN", "GITHUB_TOKEN"}
+
+/*
+GetGitHubToken returns the token used to authenticate with the GitHub API, or an empty string if none is set.
+The token is taken from the first of these that is set:
+  - The PROTO_GITHUB_TOKEN or GITHUB_TOKEN environment variables
+  - The auth.githubtoken config value
+  - The credentials file at auth.githubcredentials, containing only the token
+
+Example:
+
+	token := GetGitHubToken()
+
+Returns:
+
+	string: The GitHub token.
+*/
+func GetGitHubToken() string {
+	for _, envVar := range gitHubTokenEnvVars {
+		if token := strings.TrimSpace(os.Getenv(envVar)); token != "" {
+			Debug("GetGitHubToken: Using token from " + envVar)
+			return token
+		}
+	}
+
+	if token := strings.TrimSpace(viper.GetString("auth.githubtoken")); token != "" {
+		Debug("GetGitHubToken: Using token from config")
+		return token
+	}
+
+	credentialsFile := viper.GetString("auth.githubcredentials")
+	if credentialsFile == "" {
+		return ""
+	}
+
+	contents, err := ioutil.ReadFile(UsePath(credentialsFile, false))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			Debug("GetGitHubToken: Unable to read credentials file: " + err.Error())
+		}
+		return ""
+	}
+
+	Debug("GetGitHubToken: Using token from credentials file")
+	return strings.TrimSpace(string(contents))
+}
+
+// tokenTransport is an http.RoundTripper that authenticates every request with a bearer token.
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests must not be modified by a RoundTripper, so authenticate a copy.
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", "Bearer "+t.token)
+
+	return t.base.RoundTrip(authed)
+}
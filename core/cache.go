@@ -0,0 +1,266 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// cachedResponse is a response from a release API stored in the metadata cache.
+type cachedResponse struct {
+	URL       string      `json:"url"`
+	ETag      string      `json:"etag"`
+	Modified  string      `json:"last_modified"`
+	FetchedAt time.Time   `json:"fetched_at"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body"`
+}
+
+// cacheTransport is an http.RoundTripper that stores release metadata responses on disk.
+// Fresh responses (younger than the cache.ttl) are served without a request, stale ones are revalidated with their ETag,
+// and in offline mode (cache.offline) everything is served from the cache without touching the network.
+type cacheTransport struct {
+	base http.RoundTripper
+}
+
+/*
+GetMetadataCacheDir returns the directory the release metadata cache is stored in.
+Example:
+
+	dir := GetMetadataCacheDir()
+	fmt.Println(dir) // $HOME/.cache/proto/metadata
+
+Returns:
+
+	string: The path to the metadata cache directory.
+*/
+func GetMetadataCacheDir() string {
+	cacheDir, _ := os.UserCacheDir()
+	return filepath.Join(cacheDir, "proto", "metadata")
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	path := cachePath(req)
+	cached, err := readCachedResponse(path)
+	if err != nil {
+		Debug("cacheTransport: Ignoring unreadable cache entry for " + req.URL.String() + ": " + err.Error())
+	}
+
+	// In offline mode the cache is the only place to get data from.
+	if viper.GetBool("cache.offline") {
+		if cached == nil {
+			return nil, fmt.Errorf("no cached data is available while offline, run the command again without --offline to fetch it")
+		}
+
+		Debug("cacheTransport: Serving " + req.URL.String() + " from cache (offline)")
+		return cached.response(req), nil
+	}
+
+	// Serve fresh entries without making a request at all.
+	if cached != nil && time.Since(cached.FetchedAt) < getCacheTTL() {
+		Debug("cacheTransport: Serving " + req.URL.String() + " from cache (fetched " + cached.FetchedAt.Local().Format("2006-01-02 15:04:05") + ")")
+		return cached.response(req), nil
+	}
+
+	// Revalidate stale entries, servers reply with 304 Not Modified if nothing has changed.
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.Modified != "" {
+			req.Header.Set("If-Modified-Since", cached.Modified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		Debug("cacheTransport: Revalidated cached " + req.URL.String())
+		resp.Body.Close()
+
+		cached.FetchedAt = time.Now()
+		writeCachedResponse(path, cached)
+
+		// Pass on the current rate limit information from the server alongside the cached data.
+		revalidated := cached.response(req)
+		for key, values := range resp.Header {
+			if strings.HasPrefix(key, "X-Ratelimit-") {
+				revalidated.Header[key] = values
+			}
+		}
+
+		return revalidated, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Rate limit information goes stale quickly, so don't keep it around where it could be mistaken for the current limit.
+	header := resp.Header.Clone()
+	for key := range header {
+		if strings.HasPrefix(key, "X-Ratelimit-") {
+			header.Del(key)
+		}
+	}
+
+	writeCachedResponse(path, &cachedResponse{
+		URL:       req.URL.Redacted(),
+		ETag:      resp.Header.Get("ETag"),
+		Modified:  resp.Header.Get("Last-Modified"),
+		FetchedAt: time.Now(),
+		Header:    header,
+		Body:      body,
+	})
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+/*
+response turns a cached response back into an HTTP response for the given request.
+Arguments:
+
+	req<*http.Request>: The request being answered.
+
+Returns:
+
+	*http.Response: The cached response.
+*/
+func (c *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+/*
+getCacheTTL returns how long cached metadata is used before it is revalidated, from the cache.ttl config value.
+Returns:
+
+	time.Duration: The time to live of cached metadata.
+*/
+func getCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(viper.GetString("cache.ttl"))
+	if err != nil {
+		Debug("getCacheTTL: Invalid cache.ttl, always revalidating: " + err.Error())
+		return 0
+	}
+
+	return ttl
+}
+
+/*
+cachePath returns the path of the cache entry for the given request.
+Entries are grouped by host, and keyed by the URL and credentials so that responses are never shared between different tokens.
+Arguments:
+
+	req<*http.Request>: The request.
+
+Returns:
+
+	string: The path to the cache entry.
+*/
+func cachePath(req *http.Request) string {
+	key := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Authorization")))
+	return filepath.Join(GetMetadataCacheDir(), req.URL.Host, fmt.Sprintf("%x.json", key))
+}
+
+/*
+readCachedResponse reads the cache entry at the given path.
+Arguments:
+
+	path<string>: The path to the cache entry.
+
+Returns:
+
+	*cachedResponse: The cached response, or nil if there is no entry.
+	error: Any errors that occur.
+*/
+func readCachedResponse(path string) (*cachedResponse, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	defer file.Close()
+
+	cached := &cachedResponse{}
+	if err := json.NewDecoder(file).Decode(cached); err != nil {
+		return nil, err
+	}
+
+	if cached.Header == nil {
+		cached.Header = http.Header{}
+	}
+
+	return cached, nil
+}
+
+/*
+writeCachedResponse atomically writes a cache entry to the given path, failures are only logged as the cache is an optimisation.
+Arguments:
+
+	path<string>: The path to the cache entry.
+	cached<*cachedResponse>: The response to store.
+*/
+func writeCachedResponse(path string, cached *cachedResponse) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		Debug("writeCachedResponse: " + err.Error())
+		return
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		Debug("writeCachedResponse: " + err.Error())
+		return
+	}
+
+	defer os.Remove(tmp.Name())
+
+	err = json.NewEncoder(tmp).Encode(cached)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		Debug("writeCachedResponse: " + err.Error())
+	}
+}
@@ -0,0 +1,460 @@
+package core
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"lukechampine.com/blake3"
+)
+
+// checksumAlgorithm is a hash algorithm that runner archives can be verified with.
+type checksumAlgorithm struct {
+	name       string
+	bsdTags    []string
+	extensions []string
+	aggregate  string
+	hexLength  int
+	weak       bool
+	new        func() hash.Hash
+}
+
+// checksumAlgorithms are the algorithms that checksum files can use, matched by the BSD style tag of a line, the file extension,
+// the name of an aggregate file covering several assets (eg. "SHA256SUMS") or by the length of the digest.
+// When the length is shared by several algorithms, the file is hashed with all of them. Weak algorithms can only detect
+// corrupted downloads rather than tampering (collisions can be crafted for both sha1 and md5), so their results are warnings
+// rather than verification.
+var checksumAlgorithms = []checksumAlgorithm{
+	{"sha512", []string{"SHA512"}, []string{".sha512sum", ".sha512"}, "sha512sums", 128, false, sha512.New},
+	{"sha256", []string{"SHA256"}, []string{".sha256sum", ".sha256"}, "sha256sums", 64, false, sha256.New},
+	{"blake2b", []string{"BLAKE2b", "BLAKE2b-512"}, []string{".b2sum", ".blake2b", ".b2"}, "b2sums", 128, false, func() hash.Hash {
+		h, _ := blake2b.New512(nil)
+		return h
+	}},
+	{"blake3", []string{"BLAKE3"}, []string{".b3sum", ".blake3", ".b3"}, "b3sums", 64, false, func() hash.Hash {
+		return blake3.New(32, nil)
+	}},
+	{"sha1", []string{"SHA1"}, []string{".sha1sum", ".sha1"}, "sha1sums", 40, true, sha1.New},
+	{"md5", []string{"MD5"}, []string{".md5sum", ".md5"}, "md5sums", 32, true, md5.New},
+}
+
+// genericAggregateName is the end of the name of aggregate checksum files that don't say which algorithm they use,
+// such as "checksums.txt" or "proton-8_checksums.txt".
+const genericAggregateName = "checksums"
+
+// checksumEntry is a single digest read from a checksum file.
+type checksumEntry struct {
+	// algorithm is the algorithm of the digest if known from the line or file, otherwise nil to detect it from its length.
+	algorithm *checksumAlgorithm
+
+	// digest is the hex encoded digest.
+	digest string
+
+	// filename is the name of the file the digest is for, or an empty string if the line didn't say.
+	filename string
+}
+
+// ChecksumVerifier checks a file against the checksums it is expected to have. The file is written to the verifier,
+// so that it can be hashed while it is being downloaded or extracted rather than read again afterwards.
+type ChecksumVerifier struct {
+	candidates []checksumCandidate
+	hashes     map[string]hash.Hash
+	writer     io.Writer
+}
+
+// checksumCandidate is an expected digest, alongside the algorithms it could have been made with.
+type checksumCandidate struct {
+	algorithms []*checksumAlgorithm
+	digest     string
+}
+
+// ChecksumResult is the outcome of verifying a file against a checksum.
+type ChecksumResult struct {
+	// Match is whether the file matched the checksum.
+	Match bool
+
+	// Algorithm is the name of the algorithm that was used, eg. "sha256".
+	Algorithm string
+
+	// Weak is whether the algorithm can only detect corruption and not tampering, in which case the result should only be warned about.
+	Weak bool
+}
+
+/*
+getChecksumAlgorithm returns the checksum algorithm of the given checksum file name.
+Arguments:
+
+	name<string>: The file name.
+
+Returns:
+
+	*checksumAlgorithm: The checksum algorithm, or nil if the file is not a supported checksum file or doesn't say which algorithm it uses.
+*/
+func getChecksumAlgorithm(name string) *checksumAlgorithm {
+	lower := strings.ToLower(name)
+	for i, algorithm := range checksumAlgorithms {
+		for _, extension := range algorithm.extensions {
+			if strings.HasSuffix(lower, extension) {
+				return &checksumAlgorithms[i]
+			}
+		}
+
+		if strings.HasSuffix(strings.TrimSuffix(lower, ".txt"), algorithm.aggregate) {
+			return &checksumAlgorithms[i]
+		}
+	}
+
+	return nil
+}
+
+/*
+IsChecksumFile returns whether the given file name is a supported checksum file.
+Arguments:
+
+	name<string>: The file name.
+
+Example:
+
+	ok := IsChecksumFile("GE-Proton8-25.sha256sum")
+	fmt.Println(ok) // true
+
+Returns:
+
+	bool: Whether the file is a supported checksum file.
+*/
+func IsChecksumFile(name string) bool {
+	return getChecksumAlgorithm(name) != nil || IsAggregateChecksumFile(name)
+}
+
+/*
+IsAggregateChecksumFile returns whether the given file name is a checksum file that covers several assets, like "SHA256SUMS" or "checksums.txt".
+Arguments:
+
+	name<string>: The file name.
+
+Example:
+
+	ok := IsAggregateChecksumFile("SHA256SUMS")
+	fmt.Println(ok) // true
+
+Returns:
+
+	bool: Whether the file is an aggregate checksum file.
+*/
+func IsAggregateChecksumFile(name string) bool {
+	base := strings.TrimSuffix(strings.ToLower(name), ".txt")
+	if strings.HasSuffix(base, genericAggregateName) {
+		return true
+	}
+
+	for _, algorithm := range checksumAlgorithms {
+		if strings.HasSuffix(base, algorithm.aggregate) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+parseChecksumFile reads every digest from the contents of a checksum file.
+Lines are either in the GNU coreutils format ("<digest>  <filename>", with an optional "*" before binary filenames),
+the BSD format ("SHA256 (<filename>) = <digest>") or are a lone digest. Blank lines and comments are skipped.
+Arguments:
+
+	contents<string>: The contents of the checksum file.
+	fileAlgorithm<*checksumAlgorithm>: The algorithm given by the name of the checksum file, or nil.
+
+Returns:
+
+	[]checksumEntry: The digests in the file.
+*/
+func parseChecksumFile(contents string, fileAlgorithm *checksumAlgorithm) []checksumEntry {
+	var entries []checksumEntry
+
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// BSD format, the algorithm is given by the line itself.
+		if tag, rest, ok := strings.Cut(line, " ("); ok && !strings.ContainsAny(tag, " \t") {
+			if filename, digest, ok := cutLast(rest, ") = "); ok {
+				entries = append(entries, checksumEntry{
+					algorithm: getBSDChecksumAlgorithm(tag),
+					digest:    strings.TrimSpace(digest),
+					filename:  filename,
+				})
+				continue
+			}
+		}
+
+		// GNU format, or a lone digest.
+		digest, filename, _ := strings.Cut(line, " ")
+		filename = strings.TrimPrefix(strings.TrimLeft(filename, " \t"), "*")
+		entries = append(entries, checksumEntry{algorithm: fileAlgorithm, digest: digest, filename: filename})
+	}
+
+	return entries
+}
+
+/*
+getBSDChecksumAlgorithm returns the checksum algorithm of the given BSD style tag, eg. "SHA256".
+Arguments:
+
+	tag<string>: The tag at the start of the line.
+
+Returns:
+
+	*checksumAlgorithm: The checksum algorithm, or nil if it isn't supported.
+*/
+func getBSDChecksumAlgorithm(tag string) *checksumAlgorithm {
+	for i, algorithm := range checksumAlgorithms {
+		for _, bsdTag := range algorithm.bsdTags {
+			if strings.EqualFold(bsdTag, tag) {
+				return &checksumAlgorithms[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+cutLast slices s around the last instance of sep.
+Arguments:
+
+	s<string>: The string to cut.
+	sep<string>: The separator.
+
+Returns:
+
+	string: The text before the separator.
+	string: The text after the separator.
+	bool: Whether the separator was found.
+*/
+func cutLast(s, sep string) (string, string, bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
+
+/*
+entriesForFile picks the digests of a checksum file that belong to the given file, by comparing the filename of each line
+against it. Lines without a filename are used when no line names the file.
+Arguments:
+
+	entries<[]checksumEntry>: The digests of the checksum file.
+	name<string>: The name of the file being verified.
+
+Returns:
+
+	[]checksumEntry: The digests belonging to the file.
+*/
+func entriesForFile(entries []checksumEntry, name string) []checksumEntry {
+	var named, unnamed []checksumEntry
+	for _, entry := range entries {
+		switch {
+		case entry.filename == "":
+			unnamed = append(unnamed, entry)
+		case path.Base(filepath.ToSlash(entry.filename)) == name:
+			named = append(named, entry)
+		}
+	}
+
+	if len(named) > 0 {
+		return named
+	}
+
+	// A digest for another file never counts, even if it is the only one in the sum file.
+	return unnamed
+}
+
+/*
+checksumAlgorithmsFor returns the algorithms a digest could have been made with, either from its "algorithm:" prefix
+(eg. "sha256:ab12...") or from its length.
+Arguments:
+
+	digest<string>: The hex encoded digest.
+
+Returns:
+
+	[]*checksumAlgorithm: The possible algorithms.
+	string: The digest without any prefix.
+*/
+func checksumAlgorithmsFor(digest string) ([]*checksumAlgorithm, string) {
+	if name, value, ok := strings.Cut(digest, ":"); ok {
+		for i, algorithm := range checksumAlgorithms {
+			if strings.EqualFold(algorithm.name, name) {
+				return []*checksumAlgorithm{&checksumAlgorithms[i]}, value
+			}
+		}
+	}
+
+	var result []*checksumAlgorithm
+	for i, algorithm := range checksumAlgorithms {
+		if algorithm.hexLength == len(digest) {
+			result = append(result, &checksumAlgorithms[i])
+		}
+	}
+
+	return result, digest
+}
+
+/*
+newChecksumVerifier creates a verifier for the given digests, detecting the algorithm of each from its length if it isn't known.
+Arguments:
+
+	entries<[]checksumEntry>: The digests to check against, the file only has to match one of them.
+
+Returns:
+
+	*ChecksumVerifier: The verifier.
+	error: An error if none of the digests use a supported algorithm.
+*/
+func newChecksumVerifier(entries []checksumEntry) (*ChecksumVerifier, error) {
+	verifier := &ChecksumVerifier{hashes: map[string]hash.Hash{}}
+	var writers []io.Writer
+	for _, entry := range entries {
+		algorithms, value := checksumAlgorithmsFor(strings.ToLower(strings.TrimSpace(entry.digest)))
+		if entry.algorithm != nil && len(value) == entry.algorithm.hexLength {
+			algorithms = []*checksumAlgorithm{entry.algorithm}
+		}
+
+		if len(algorithms) == 0 {
+			continue
+		}
+
+		verifier.candidates = append(verifier.candidates, checksumCandidate{algorithms, value})
+
+		// Every algorithm only has to hash the file once, however many digests use it.
+		for _, algorithm := range algorithms {
+			if _, ok := verifier.hashes[algorithm.name]; !ok {
+				verifier.hashes[algorithm.name] = algorithm.new()
+				writers = append(writers, verifier.hashes[algorithm.name])
+			}
+		}
+	}
+
+	if len(verifier.candidates) == 0 {
+		return nil, fmt.Errorf("no supported checksums were found, expected a sha512, sha256, blake2b, blake3, sha1 or md5 digest")
+	}
+
+	verifier.writer = io.MultiWriter(writers...)
+	return verifier, nil
+}
+
+// MissingChecksumError is returned when a checksum file (eg. "SHA256SUMS") doesn't list the file being verified.
+type MissingChecksumError struct {
+	SumFile string
+	Name    string
+}
+
+func (e *MissingChecksumError) Error() string {
+	return fmt.Sprintf("%s does not contain a checksum for %s", e.SumFile, e.Name)
+}
+
+/*
+NewChecksumFileVerifier creates a verifier for the checksum listed for the given file name in the given sum file, which can cover
+several files (eg. "SHA256SUMS"). The algorithm is picked from the line itself (BSD format), the sum file's name or from the length of the digest.
+Arguments:
+
+	sumPath<string>: The path to the sum file.
+	name<string>: The name of the file being verified.
+
+Example:
+
+	verifier, err := NewChecksumFileVerifier("$HOME/Downloads/SHA256SUMS", "GE-Proton8-25.tar.gz")
+
+Returns:
+
+	*ChecksumVerifier: The verifier.
+	error: A *MissingChecksumError if the sum file doesn't list the file, or an error if its checksum isn't supported.
+*/
+func NewChecksumFileVerifier(sumPath, name string) (*ChecksumVerifier, error) {
+	sum, err := ioutil.ReadFile(sumPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only use the checksums for this file, as the sum file might cover every asset of a release.
+	entries := entriesForFile(parseChecksumFile(string(sum), getChecksumAlgorithm(filepath.Base(sumPath))), name)
+	if len(entries) == 0 {
+		return nil, &MissingChecksumError{SumFile: filepath.Base(sumPath), Name: name}
+	}
+
+	verifier, err := newChecksumVerifier(entries)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(sumPath), err)
+	}
+
+	return verifier, nil
+}
+
+/*
+NewDigestVerifier creates a verifier for the given hex encoded digest.
+The algorithm is picked from the length of the digest, or given as a prefix (eg. "blake3:ab12...").
+Arguments:
+
+	digest<string>: The expected digest.
+
+Example:
+
+	verifier, err := NewDigestVerifier("sha256:ab12...")
+
+Returns:
+
+	*ChecksumVerifier: The verifier.
+	error: An error if the digest doesn't use a supported algorithm.
+*/
+func NewDigestVerifier(digest string) (*ChecksumVerifier, error) {
+	return newChecksumVerifier([]checksumEntry{{digest: digest}})
+}
+
+func (v *ChecksumVerifier) Write(p []byte) (int, error) {
+	return v.writer.Write(p)
+}
+
+/*
+Result compares everything written to the verifier so far against the expected digests.
+Returns:
+
+	*ChecksumResult: The outcome of the check, reporting the strongest algorithm that was checked if nothing matches.
+*/
+func (v *ChecksumVerifier) Result() *ChecksumResult {
+	digests := make(map[string]string, len(v.hashes))
+	for name, h := range v.hashes {
+		digests[name] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	first := v.candidates[0].algorithms[0]
+	result := &ChecksumResult{Algorithm: first.name, Weak: first.weak}
+	for _, candidate := range v.candidates {
+		for _, algorithm := range candidate.algorithms {
+			Debug("ChecksumVerifier: Comparing " + algorithm.name + " digest")
+
+			if digests[algorithm.name] == candidate.digest {
+				return &ChecksumResult{Match: true, Algorithm: algorithm.name, Weak: algorithm.weak}
+			}
+
+			if result.Weak && !algorithm.weak {
+				result = &ChecksumResult{Algorithm: algorithm.name}
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseChecksumFile(t *testing.T) {
+	sha256 := getChecksumAlgorithm("x.sha256sum")
+	sha512 := getBSDChecksumAlgorithm("SHA512")
+
+	tests := []struct {
+		name          string
+		contents      string
+		fileAlgorithm *checksumAlgorithm
+		want          []checksumEntry
+	}{
+		{
+			name:     "gnu format",
+			contents: "abc123  GE-Proton8-25.tar.gz\n",
+			want:     []checksumEntry{{digest: "abc123", filename: "GE-Proton8-25.tar.gz"}},
+		},
+		{
+			name:     "gnu binary format",
+			contents: "abc123 *GE-Proton8-25.tar.gz",
+			want:     []checksumEntry{{digest: "abc123", filename: "GE-Proton8-25.tar.gz"}},
+		},
+		{
+			name:          "algorithm from the file name",
+			contents:      "abc123  a.tar.gz",
+			fileAlgorithm: sha256,
+			want:          []checksumEntry{{algorithm: sha256, digest: "abc123", filename: "a.tar.gz"}},
+		},
+		{
+			name:     "bsd format",
+			contents: "SHA512 (GE-Proton8-25 (1).tar.gz) = abc123",
+			want:     []checksumEntry{{algorithm: sha512, digest: "abc123", filename: "GE-Proton8-25 (1).tar.gz"}},
+		},
+		{
+			name:     "lone digest",
+			contents: "  abc123\r\n",
+			want:     []checksumEntry{{digest: "abc123"}},
+		},
+		{
+			name:     "comments and blank lines",
+			contents: "# Generated by CI\n\nabc123  a.tar.gz\n\ndef456  b.tar.gz\n",
+			want: []checksumEntry{
+				{digest: "abc123", filename: "a.tar.gz"},
+				{digest: "def456", filename: "b.tar.gz"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got := parseChecksumFile(test.contents, test.fileAlgorithm)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseChecksumFile() = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEntriesForFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []checksumEntry
+		file    string
+		want    []string
+	}{
+		{
+			name:    "matching entry",
+			entries: []checksumEntry{{digest: "1", filename: "a.tar.gz"}, {digest: "2", filename: "b.tar.gz"}},
+			file:    "b.tar.gz",
+			want:    []string{"2"},
+		},
+		{
+			name:    "entry in a subdirectory",
+			entries: []checksumEntry{{digest: "1", filename: "./dist/a.tar.gz"}},
+			file:    "a.tar.gz",
+			want:    []string{"1"},
+		},
+		{
+			name:    "unnamed entry",
+			entries: []checksumEntry{{digest: "1"}},
+			file:    "a.tar.gz",
+			want:    []string{"1"},
+		},
+		{
+			name:    "named entries are preferred over unnamed ones",
+			entries: []checksumEntry{{digest: "1"}, {digest: "2", filename: "a.tar.gz"}},
+			file:    "a.tar.gz",
+			want:    []string{"2"},
+		},
+		{
+			name:    "only entry is for another file",
+			entries: []checksumEntry{{digest: "1", filename: "other.tar.gz"}},
+			file:    "a.tar.gz",
+			want:    nil,
+		},
+		{
+			name:    "no entry for the file",
+			entries: []checksumEntry{{digest: "1", filename: "b.tar.gz"}, {digest: "2", filename: "c.tar.gz"}},
+			file:    "a.tar.gz",
+			want:    nil,
+		},
+	}
+
+	for _, test := range tests {
+		var got []string
+		for _, entry := range entriesForFile(test.entries, test.file) {
+			got = append(got, entry.digest)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: entriesForFile() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewChecksumFileVerifier(t *testing.T) {
+	dir := t.TempDir()
+	sums := map[string]string{
+		"a.tar.gz.sha256sum": "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  a.tar.gz\n",
+		"SHA256SUMS":         "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  a.tar.gz\n0000000000000000000000000000000000000000000000000000000000000000  b.tar.gz\n",
+		"x.sha512sum":        "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c  other.tar.gz\n",
+	}
+	for name, contents := range sums {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		sumFile string
+		file    string
+		match   bool
+		missing bool
+	}{
+		{"a.tar.gz.sha256sum", "a.tar.gz", true, false},
+		{"SHA256SUMS", "a.tar.gz", true, false},
+		{"SHA256SUMS", "b.tar.gz", false, false},
+		{"SHA256SUMS", "c.tar.gz", false, true},
+		{"x.sha512sum", "a.tar.gz", false, true},
+	}
+
+	for _, test := range tests {
+		verifier, err := NewChecksumFileVerifier(filepath.Join(dir, test.sumFile), test.file)
+		if test.missing {
+			var missing *MissingChecksumError
+			if !errors.As(err, &missing) {
+				t.Errorf("NewChecksumFileVerifier(%s, %s) = %v, want a missing checksum error", test.sumFile, test.file, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("NewChecksumFileVerifier(%s, %s) = %v", test.sumFile, test.file, err)
+			continue
+		}
+
+		verifier.Write([]byte("foo\n"))
+		if result := verifier.Result(); result.Match != test.match || result.Algorithm != "sha256" {
+			t.Errorf("NewChecksumFileVerifier(%s, %s).Result() = %+v, want match %v with sha256", test.sumFile, test.file, result, test.match)
+		}
+	}
+}
+
+func TestNewDigestVerifierWeak(t *testing.T) {
+	tests := []struct {
+		digest    string
+		algorithm string
+		weak      bool
+	}{
+		{"b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c", "sha256", false},
+		{"sha1:f1d2d2f924e986ac86fdf7b36c94bcdf32beec15", "sha1", true},
+		{"d3b07384d113edec49eaa6238ad5ff00", "md5", true},
+	}
+
+	for _, test := range tests {
+		verifier, err := NewDigestVerifier(test.digest)
+		if err != nil {
+			t.Errorf("NewDigestVerifier(%s) = %v", test.digest, err)
+			continue
+		}
+
+		verifier.Write([]byte("foo\n"))
+		if result := verifier.Result(); !result.Match || result.Algorithm != test.algorithm || result.Weak != test.weak {
+			t.Errorf("NewDigestVerifier(%s).Result() = %+v, want a match with %s and weak %v", test.digest, result, test.algorithm, test.weak)
+		}
+	}
+}
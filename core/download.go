@@ -0,0 +1,773 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/viper"
+)
+
+// Downloads are written next to their final path with this suffix, and only renamed into place once complete.
+// A partial file left behind by a failed attempt is resumed from where it stopped rather than downloaded again.
+const partialDownloadSuffix = ".part"
+
+// Failed downloads are retried after a delay that starts at initialRetryDelay and doubles every attempt, up to maxRetryDelay.
+const (
+	initialRetryDelay = time.Second
+	maxRetryDelay     = 30 * time.Second
+)
+
+// Defaults for when the download config values are missing or invalid.
+const (
+	defaultConnectTimeout = 30 * time.Second
+	defaultReadTimeout    = 60 * time.Second
+	defaultRetries        = 5
+)
+
+// downloadBarTemplate is the template of the progress bar shown while downloading.
+const downloadBarTemplate = `{{ cycle . "⠃" "⠆" "⠤" "⠰" "⠘" "⠉" }} Installing {{string . "src"}} [{{percent .}} | {{speed . "%s/s"}} | {{ rtime .}}]`
+
+// httpAssetSource is implemented by sources that serve their assets over plain HTTP,
+// which lets them be downloaded with retries and resumed with range requests rather than streamed through OpenAsset.
+type httpAssetSource interface {
+	// assetURL returns the HTTP URL of the given asset, or false if it can't be fetched over HTTP.
+	assetURL(asset *Asset) (string, bool)
+}
+
+// localAssetSource is implemented by sources whose assets are already files on disk, which are installed from where they are
+// rather than copied to the download cache first.
+type localAssetSource interface {
+	// assetPath returns the path of the given asset on disk, or false if it isn't a local file.
+	assetPath(asset *Asset) (string, bool)
+}
+
+// DownloadStatusError is returned when a server answers a download with anything other than the file (eg. a 404 page).
+type DownloadStatusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+}
+
+func (e *DownloadStatusError) Error() string {
+	return fmt.Sprintf("unexpected response from %s: %s", e.URL, e.Status)
+}
+
+// retryable returns whether the error is one that might go away by itself, such as the server being overloaded.
+func (e *DownloadStatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout
+}
+
+// idleTimeoutReader cancels a response whenever no data has been read from it for the read timeout, so a stalled
+// connection fails instead of hanging forever without limiting how long a large download can take overall.
+type idleTimeoutReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired int32
+}
+
+// resumableReader reads a file over HTTP, reconnecting with exponential backoff when the connection fails and continuing from
+// where it stopped with a range request, or by skipping what was already read for servers that don't support ranges.
+// Range requests are made with the ETag or Last-Modified date of the first response, so that a file that changed on the server
+// in the meantime is never stitched together from two versions.
+type resumableReader struct {
+	ctx         context.Context
+	client      *http.Client
+	url         string
+	name        string
+	offset      int64
+	start       int64
+	size        int64
+	validator   string
+	opened      bool
+	readTimeout time.Duration
+	body        io.ReadCloser
+	failure     error
+	retries     int
+	attempt     int
+	delay       time.Duration
+}
+
+// errResumeFailed is returned when a partially downloaded file can't be continued, and has to be downloaded again from the start.
+var errResumeFailed = errors.New("unable to resume the download")
+
+// openFunc opens a file starting from the given offset if possible, returning its contents, its total size (or -1 if unknown)
+// and the offset the contents actually start at (0 if the file can't be opened part way through).
+type openFunc func(ctx context.Context, offset int64) (io.ReadCloser, int64, int64, error)
+
+// errorReader remembers the error its reader failed with, if any.
+type errorReader struct {
+	r   io.Reader
+	err error
+}
+
+/*
+GetDownloadTimeouts returns how long to wait for a connection to a server, and how long to wait for data on an open connection,
+from the download.connecttimeout and download.readtimeout config values.
+Example:
+
+	connect, read := GetDownloadTimeouts()
+	fmt.Println(connect, read) // 30s 1m0s
+
+Returns:
+
+	time.Duration: The connect timeout.
+	time.Duration: The read timeout.
+*/
+func GetDownloadTimeouts() (time.Duration, time.Duration) {
+	return getDuration("download.connecttimeout", defaultConnectTimeout), getDuration("download.readtimeout", defaultReadTimeout)
+}
+
+/*
+GetDownloadRetries returns how many times a failed download is retried, from the download.retries config value.
+Example:
+
+	retries := GetDownloadRetries()
+	fmt.Println(retries) // 5
+
+Returns:
+
+	int: The number of retries.
+*/
+func GetDownloadRetries() int {
+	retries, err := strconv.Atoi(viper.GetString("download.retries"))
+	if err != nil || retries < 0 {
+		Debug("GetDownloadRetries: Invalid download.retries, using the default")
+		return defaultRetries
+	}
+
+	return retries
+}
+
+/*
+getDuration returns the duration stored at the given config key, or the fallback if it is missing or invalid.
+Arguments:
+
+	key<string>: The config key.
+	fallback<time.Duration>: The duration to use if the config value is invalid.
+
+Returns:
+
+	time.Duration: The duration.
+*/
+func getDuration(key string, fallback time.Duration) time.Duration {
+	duration, err := time.ParseDuration(viper.GetString(key))
+	if err != nil || duration <= 0 {
+		Debug("getDuration: Invalid " + key + ", using the default of " + fallback.String())
+		return fallback
+	}
+
+	return duration
+}
+
+/*
+newDownloadHTTPClient creates the HTTP client used to download assets, which gives up on servers that can't be reached
+or stop responding within the configured timeouts.
+Returns:
+
+	*http.Client: The HTTP client.
+*/
+func newDownloadHTTPClient() *http.Client {
+	connectTimeout, readTimeout := GetDownloadTimeouts()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = readTimeout
+
+	return &http.Client{Transport: transport}
+}
+
+/*
+newIdleTimeoutReader wraps the given response body so that cancel is called once no data has been read for the given timeout.
+Arguments:
+
+	body<io.ReadCloser>: The response body.
+	timeout<time.Duration>: How long to wait for data.
+	cancel<context.CancelFunc>: Cancels the request the body belongs to.
+
+Returns:
+
+	*idleTimeoutReader: The wrapped body.
+*/
+func newIdleTimeoutReader(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	r := &idleTimeoutReader{body: body, timeout: timeout, cancel: cancel}
+	r.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&r.expired, 1)
+		cancel()
+	})
+
+	return r
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if err != nil && atomic.LoadInt32(&r.expired) == 1 {
+		return n, fmt.Errorf("no data was received for %s", r.timeout)
+	}
+
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+
+	return n, err
+}
+
+func (r *idleTimeoutReader) Close() error {
+	r.timer.Stop()
+	r.cancel()
+	return r.body.Close()
+}
+
+/*
+openURLFrom returns an opener for the file at the given URL, which is retried and resumed as needed.
+Arguments:
+
+	url<string>: The URL of the file.
+	name<string>: The name of the file, for messages.
+
+Returns:
+
+	openFunc: The opener.
+*/
+func openURLFrom(url, name string) openFunc {
+	return func(ctx context.Context, offset int64) (io.ReadCloser, int64, int64, error) {
+		r, err := openResumable(ctx, url, name, offset)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		return r, r.size, r.start, nil
+	}
+}
+
+/*
+openAssetFrom returns an opener for the given release asset. Assets served over HTTP are retried and resumed as needed,
+anything else is opened through the source and always starts from the beginning.
+Arguments:
+
+	source<Source>: The source that published the asset.
+	asset<*Asset>: The asset.
+
+Returns:
+
+	openFunc: The opener.
+*/
+func openAssetFrom(source Source, asset *Asset) openFunc {
+	if httpSource, ok := source.(httpAssetSource); ok {
+		if url, ok := httpSource.assetURL(asset); ok {
+			return openURLFrom(url, asset.Name)
+		}
+	}
+
+	return func(ctx context.Context, offset int64) (io.ReadCloser, int64, int64, error) {
+		body, size, err := source.OpenAsset(ctx, asset)
+		return body, size, 0, err
+	}
+}
+
+/*
+GetLocalAssetPath returns the path of the given release asset if its source keeps it on disk (eg. a "dir:" source), in which case
+it can be installed directly instead of being downloaded.
+Arguments:
+
+	source<Source>: The source that published the asset.
+	asset<*Asset>: The asset.
+
+Example:
+
+	path, ok := GetLocalAssetPath(source, asset)
+	fmt.Println(path, ok) // /mnt/runners/GE-Proton8-25.tar.gz true
+
+Returns:
+
+	string: The path of the asset.
+	bool: Whether the asset is a local file.
+*/
+func GetLocalAssetPath(source Source, asset *Asset) (string, bool) {
+	if localSource, ok := source.(localAssetSource); ok {
+		return localSource.assetPath(asset)
+	}
+
+	return "", false
+}
+
+/*
+GetRemainingDownloadSize returns how much of a file still has to be downloaded to the given path, as a partial file left
+behind by an earlier attempt is resumed rather than downloaded again.
+Arguments:
+
+	path<string>: The path the file will be downloaded to.
+	size<int64>: The size of the file in bytes.
+
+Returns:
+
+	int64: The number of bytes left to download.
+*/
+func GetRemainingDownloadSize(path string, size int64) int64 {
+	if info, err := os.Stat(path + partialDownloadSuffix); err == nil && info.Size() < size {
+		return size - info.Size()
+	}
+
+	return size
+}
+
+/*
+fetch downloads a file to the given path while passing its contents to consume as they arrive, the file is only moved into
+place once it has been completely downloaded. A partial file left behind by an earlier attempt is read back from disk first,
+and only the rest of the file is downloaded.
+Arguments:
+
+	ctx<context.Context>: The context for the download.
+	open<openFunc>: Opens the file.
+	path<string>: The path to download the file to.
+	name<string>: The name to display in the progress bar.
+	consume<func(io.Reader) error>: Reads the contents of the file as it is downloaded, or nil. Anything it doesn't read is still saved.
+
+Returns:
+
+	os.FileInfo: The file that was downloaded.
+	error: An error if one occurs.
+*/
+func fetch(ctx context.Context, open openFunc, path, name string, consume func(r io.Reader) error) (os.FileInfo, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	partPath := path + partialDownloadSuffix
+	partial, err := os.OpenFile(partPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	defer partial.Close()
+
+	info, err := partial.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	body, size, offset, err := open(ctx, info.Size())
+	if errors.Is(err, errResumeFailed) {
+		Debug("fetch: Restarting the download of " + name + ": " + err.Error())
+		body, size, offset, err = open(ctx, 0)
+	}
+
+	if err != nil {
+		// There is nothing worth resuming from a file the server refuses to serve.
+		var statusErr *DownloadStatusError
+		if errors.As(err, &statusErr) || errors.Is(err, errResumeFailed) {
+			partial.Close()
+			os.Remove(partPath)
+		}
+		return nil, err
+	}
+
+	defer body.Close()
+
+	if offset == 0 {
+		if err := partial.Truncate(0); err != nil {
+			return nil, err
+		}
+	} else {
+		Debug(fmt.Sprintf("fetch: Resuming %s from byte %d", name, offset))
+	}
+
+	// Everything downloaded is saved, on top of whatever was already downloaded by an earlier attempt.
+	downloaded := &errorReader{r: io.TeeReader(body, partial)}
+	contents := io.MultiReader(io.NewSectionReader(partial, 0, offset), downloaded)
+
+	bar := pb.ProgressBarTemplate(downloadBarTemplate).Start64(size).Set("src", name)
+	reader := bar.NewProxyReader(contents)
+
+	if consume != nil {
+		if err := consume(reader); err != nil {
+			// A partial file that can't be read is most likely corrupt, so don't resume from it next time.
+			if (downloaded.err == nil && ctx.Err() == nil) || errors.Is(downloaded.err, errResumeFailed) {
+				partial.Close()
+				os.Remove(partPath)
+			}
+			return nil, err
+		}
+	}
+
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		// The file changed on the server part way through, so what was downloaded can't be resumed from.
+		if errors.Is(err, errResumeFailed) {
+			partial.Close()
+			os.Remove(partPath)
+		}
+		return nil, err
+	}
+
+	bar.Finish()
+
+	if err := partial.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(partPath, path); err != nil {
+		return nil, err
+	}
+
+	Debug("fetch: Downloaded file to: " + path)
+
+	return os.Stat(path)
+}
+
+/*
+openResumable opens the file at the given URL from the given offset, retrying with exponential backoff until it responds.
+Arguments:
+
+	ctx<context.Context>: The context for the download.
+	url<string>: The URL of the file.
+	name<string>: The name of the file, for messages.
+	offset<int64>: The position to start reading from.
+
+Returns:
+
+	*resumableReader: The contents of the file from the offset.
+	error: An error if the file couldn't be opened after every retry.
+*/
+func openResumable(ctx context.Context, url, name string, offset int64) (*resumableReader, error) {
+	_, readTimeout := GetDownloadTimeouts()
+	r := &resumableReader{
+		ctx:         ctx,
+		client:      newDownloadHTTPClient(),
+		url:         url,
+		name:        name,
+		offset:      offset,
+		start:       offset,
+		size:        -1,
+		readTimeout: readTimeout,
+		retries:     GetDownloadRetries(),
+		delay:       initialRetryDelay,
+	}
+
+	if err := r.connect(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *resumableReader) Read(p []byte) (int, error) {
+	for {
+		if r.body == nil {
+			if err := r.connect(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+
+		if err == io.EOF && r.size >= 0 && r.offset < r.size {
+			err = fmt.Errorf("the connection was closed after %d of %d bytes: %w", r.offset, r.size, io.ErrUnexpectedEOF)
+		}
+
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+
+		// Pick up from where the connection failed, handing over whatever was read before it did first.
+		r.body.Close()
+		r.body = nil
+		if n > 0 {
+			r.failure = err
+			return n, nil
+		}
+
+		if err := r.backoff(err); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (r *resumableReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+
+	return r.body.Close()
+}
+
+/*
+connect opens the file from the current offset, retrying until it succeeds or runs out of retries.
+Returns:
+
+	error: An error if the file couldn't be opened.
+*/
+func (r *resumableReader) connect() error {
+	// A failure from the previous connection still counts towards the retries.
+	if r.failure != nil {
+		failure := r.failure
+		r.failure = nil
+		if err := r.backoff(failure); err != nil {
+			return err
+		}
+	}
+
+	for {
+		err := r.open()
+		if err == nil {
+			return nil
+		}
+
+		if err := r.backoff(err); err != nil {
+			return err
+		}
+	}
+}
+
+/*
+backoff waits before the next attempt after the given failure, doubling the wait every time.
+Arguments:
+
+	cause<error>: Why the last attempt failed.
+
+Returns:
+
+	error: The error to give up with, if the failure can't be retried or there are no retries left.
+*/
+func (r *resumableReader) backoff(cause error) error {
+	var statusErr *DownloadStatusError
+	if (errors.As(cause, &statusErr) && !statusErr.retryable()) || errors.Is(cause, errResumeFailed) {
+		return cause
+	}
+
+	if r.attempt >= r.retries || r.ctx.Err() != nil {
+		if r.attempt == 0 {
+			return cause
+		}
+		return fmt.Errorf("downloading %s failed after %d attempts: %w", r.name, r.attempt+1, cause)
+	}
+
+	r.attempt++
+	fmt.Printf("\nDownloading %s failed (%v), retrying in %s (attempt %d of %d)\n", r.name, cause, r.delay, r.attempt+1, r.retries+1)
+
+	select {
+	case <-time.After(r.delay):
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+
+	r.delay *= 2
+	if r.delay > maxRetryDelay {
+		r.delay = maxRetryDelay
+	}
+
+	return nil
+}
+
+/*
+open makes a single request for the file from the current offset.
+Returns:
+
+	error: An error if the server didn't respond with the file.
+*/
+func (r *resumableReader) open() error {
+	ctx, cancel := context.WithCancel(r.ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	if r.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+
+		// Only resume if the file is still the one that was being read, the server sends all of it otherwise.
+		if r.validator != "" {
+			req.Header.Set("If-Range", r.validator)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	body := newIdleTimeoutReader(resp.Body, r.readTimeout, cancel)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		validator := responseValidator(resp)
+
+		// Nothing has been read yet, so start over rather than trust a partial file that may be of an older version of the file.
+		if r.offset > 0 && !r.opened {
+			Debug(fmt.Sprintf("resumableReader: Server sent all of %s instead of resuming from byte %d, starting over", r.name, r.offset))
+			r.offset, r.start = 0, 0
+		}
+
+		if r.offset > 0 {
+			if r.validator != "" && validator != r.validator {
+				body.Close()
+				return fmt.Errorf("%s has changed on the server while it was being downloaded: %w", r.name, errResumeFailed)
+			}
+
+			// The server sent the whole file as it doesn't support ranges, so skip what has already been read.
+			Debug(fmt.Sprintf("resumableReader: Server does not support resuming, skipping the first %d bytes of %s", r.offset, r.name))
+			if _, err := io.CopyN(io.Discard, body, r.offset); err != nil {
+				body.Close()
+				return err
+			}
+		}
+
+		if resp.ContentLength >= 0 {
+			r.size = resp.ContentLength
+		}
+
+		if r.validator == "" {
+			r.validator = validator
+		}
+	case http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != r.offset {
+			body.Close()
+			return fmt.Errorf("the server resumed %s from the wrong position: %w", r.name, errResumeFailed)
+		}
+
+		switch {
+		case total >= 0:
+			r.size = total
+		case resp.ContentLength >= 0:
+			r.size = r.offset + resp.ContentLength
+		}
+
+		if r.validator == "" {
+			r.validator = responseValidator(resp)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		body.Close()
+
+		// The file is either already complete, or shorter than what was read (meaning it has changed on the server).
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total == r.offset {
+			r.size = total
+			r.body = io.NopCloser(strings.NewReader(""))
+			r.opened = true
+			return nil
+		}
+
+		return fmt.Errorf("%s has changed on the server since it was partially downloaded: %w", r.name, errResumeFailed)
+	default:
+		body.Close()
+		return &DownloadStatusError{URL: r.url, Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+
+	r.body = body
+	r.opened = true
+	return nil
+}
+
+/*
+responseValidator returns the value to send as the If-Range header when resuming the file of the given response, which is
+its ETag, or its Last-Modified date if it doesn't have a strong ETag.
+Arguments:
+
+	resp<*http.Response>: The response with the file.
+
+Returns:
+
+	string: The validator, or an empty string if the response has neither.
+*/
+func responseValidator(resp *http.Response) string {
+	// Weak ETags can't be used with If-Range.
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return resp.Header.Get("Last-Modified")
+}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+
+	return n, err
+}
+
+/*
+parseContentRange parses the Content-Range header of a partial response (eg. "bytes 100-199/1000"), where unsatisfied ranges have an asterisk in place of the byte range.
+Arguments:
+
+	header<string>: The value of the header.
+
+Returns:
+
+	int64: The position of the first byte in the response, or -1 if the range is unsatisfied.
+	int64: The size of the whole file, or -1 if unknown.
+	bool: Whether the header could be parsed.
+*/
+func parseContentRange(header string) (int64, int64, bool) {
+	spec, found := cutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+
+	byteRange, rawTotal, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	total := int64(-1)
+	if rawTotal != "*" {
+		parsed, err := strconv.ParseInt(rawTotal, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total = parsed
+	}
+
+	if byteRange == "*" {
+		return -1, total, true
+	}
+
+	rawStart, _, found := strings.Cut(byteRange, "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(rawStart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return start, total, true
+}
+
+/*
+cutPrefix returns the string without the given prefix, and whether it had the prefix.
+Arguments:
+
+	s<string>: The string.
+	prefix<string>: The prefix to remove.
+
+Returns:
+
+	string: The string without the prefix.
+	bool: Whether the string had the prefix.
+*/
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+
+	return s[len(prefix):], true
+}
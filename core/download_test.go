@@ -0,0 +1,352 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header string
+		start  int64
+		total  int64
+		ok     bool
+	}{
+		{"bytes 100-199/1000", 100, 1000, true},
+		{"bytes 0-0/1", 0, 1, true},
+		{"bytes 100-199/*", 100, -1, true},
+		{"bytes */1000", -1, 1000, true},
+		{"bytes */*", -1, -1, true},
+		{"", 0, 0, false},
+		{"100-199/1000", 0, 0, false},
+		{"bytes 100-199", 0, 0, false},
+		{"bytes abc-199/1000", 0, 0, false},
+		{"bytes 100-199/abc", 0, 0, false},
+		{"bytes 100/1000", 0, 0, false},
+		{"items 100-199/1000", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		start, total, ok := parseContentRange(test.header)
+		if ok != test.ok || (ok && (start != test.start || total != test.total)) {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v, want %d, %d, %v", test.header, start, total, ok, test.start, test.total, test.ok)
+		}
+	}
+}
+
+// testDownload is the file served to resumableReader tests.
+var testDownload = bytes.Repeat([]byte("0123456789"), 1000)
+
+// downloadServer serves testDownload, misbehaving in the ways a test asks for and recording the ranges it was asked for.
+type downloadServer struct {
+	mu       sync.Mutex
+	ranges   []string
+	ifRanges []string
+
+	// etags are the ETags of the file for each response in turn, the last one is kept for the rest.
+	etags []string
+
+	// drops is how many responses are cut off halfway through.
+	drops int
+
+	// failures is how many requests are answered with the failure status before the file is served.
+	failures int
+	status   int
+
+	// noRanges makes the server ignore range requests and always send the whole file.
+	noRanges bool
+
+	// wrongStart makes the server answer range requests from the wrong position.
+	wrongStart bool
+}
+
+func (s *downloadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.ifRanges = append(s.ifRanges, r.Header.Get("If-Range"))
+	if len(s.etags) > 0 {
+		w.Header().Set("ETag", s.etags[0])
+		if len(s.etags) > 1 {
+			s.etags = s.etags[1:]
+		}
+	}
+	drop := s.drops > 0
+	if drop {
+		s.drops--
+	}
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	s.mu.Unlock()
+
+	if fail {
+		w.WriteHeader(s.status)
+		return
+	}
+
+	if s.wrongStart && r.Header.Get("Range") != "" {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(testDownload)-1, len(testDownload)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(testDownload)
+		return
+	}
+
+	if s.noRanges {
+		r.Header.Del("Range")
+	}
+
+	if !drop {
+		http.ServeContent(w, r, "file.tar.gz", time.Time{}, bytes.NewReader(testDownload))
+		return
+	}
+
+	// Promise the whole file, then close the connection halfway through it.
+	w.Header().Set("Content-Length", strconv.Itoa(len(testDownload)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(testDownload[:len(testDownload)/2])
+	w.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+/*
+newTestResumableReader creates a resumableReader for the given server that retries without waiting.
+Arguments:
+
+	t<*testing.T>: The test.
+	server<*downloadServer>: The server to download from.
+	offset<int64>: The position to start reading from.
+
+Returns:
+
+	*resumableReader: The reader, which isn't connected yet.
+*/
+func newTestResumableReader(t *testing.T, server *downloadServer, offset int64) *resumableReader {
+	t.Helper()
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	return &resumableReader{
+		ctx:         context.Background(),
+		client:      httpServer.Client(),
+		url:         httpServer.URL + "/file.tar.gz",
+		name:        "file.tar.gz",
+		offset:      offset,
+		size:        -1,
+		readTimeout: time.Minute,
+		retries:     3,
+		delay:       time.Millisecond,
+	}
+}
+
+func TestResumableReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		server *downloadServer
+		offset int64
+		want   []byte
+		ranges []string
+	}{
+		{
+			name:   "complete download",
+			server: &downloadServer{},
+			want:   testDownload,
+			ranges: []string{""},
+		},
+		{
+			name:   "resumed after the connection drops",
+			server: &downloadServer{drops: 1},
+			want:   testDownload,
+			ranges: []string{"", "bytes=5000-"},
+		},
+		{
+			name:   "resumed from an earlier download",
+			server: &downloadServer{},
+			offset: 9000,
+			want:   testDownload[9000:],
+			ranges: []string{"bytes=9000-"},
+		},
+		{
+			name:   "server without range support",
+			server: &downloadServer{drops: 1, noRanges: true},
+			want:   testDownload,
+			ranges: []string{"", "bytes=5000-"},
+		},
+		{
+			name:   "earlier download from a server without range support",
+			server: &downloadServer{noRanges: true},
+			offset: 9000,
+			want:   testDownload,
+			ranges: []string{"bytes=9000-"},
+		},
+		{
+			name:   "retried after a server error",
+			server: &downloadServer{failures: 2, status: http.StatusServiceUnavailable},
+			want:   testDownload,
+			ranges: []string{"", "", ""},
+		},
+		{
+			name:   "already complete",
+			server: &downloadServer{},
+			offset: int64(len(testDownload)),
+			want:   []byte{},
+			ranges: []string{fmt.Sprintf("bytes=%d-", len(testDownload))},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestResumableReader(t, test.server, test.offset)
+			if err := r.connect(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("read %d bytes, want %d bytes", len(got), len(test.want))
+			}
+
+			if r.size != int64(len(testDownload)) {
+				t.Errorf("size = %d, want %d", r.size, len(testDownload))
+			}
+
+			if fmt.Sprint(test.server.ranges) != fmt.Sprint(test.ranges) {
+				t.Errorf("requested ranges %q, want %q", test.server.ranges, test.ranges)
+			}
+		})
+	}
+}
+
+func TestResumableReaderIfRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   *downloadServer
+		want     []byte
+		ifRanges []string
+	}{
+		{
+			name:     "unchanged file",
+			server:   &downloadServer{drops: 1, etags: []string{`"v1"`}},
+			want:     testDownload,
+			ifRanges: []string{"", `"v1"`},
+		},
+		{
+			name:     "unchanged file on a server without range support",
+			server:   &downloadServer{drops: 1, noRanges: true, etags: []string{`"v1"`}},
+			want:     testDownload,
+			ifRanges: []string{"", `"v1"`},
+		},
+		{
+			name:     "file changed while downloading",
+			server:   &downloadServer{drops: 1, etags: []string{`"v1"`, `"v2"`}},
+			ifRanges: []string{"", `"v1"`},
+		},
+		{
+			name:     "weak ETag",
+			server:   &downloadServer{drops: 1, etags: []string{`W/"v1"`}},
+			want:     testDownload,
+			ifRanges: []string{"", ""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestResumableReader(t, test.server, 0)
+			if err := r.connect(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := io.ReadAll(r)
+			r.Close()
+
+			if test.want == nil {
+				if !errors.Is(err, errResumeFailed) {
+					t.Errorf("io.ReadAll() = %v, want %v", err, errResumeFailed)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(got, test.want) {
+				t.Errorf("read %d bytes, want %d bytes", len(got), len(test.want))
+			}
+
+			if fmt.Sprint(test.server.ifRanges) != fmt.Sprint(test.ifRanges) {
+				t.Errorf("sent If-Range headers %q, want %q", test.server.ifRanges, test.ifRanges)
+			}
+		})
+	}
+}
+
+func TestResumableReaderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   *downloadServer
+		offset   int64
+		requests int
+		check    func(err error) bool
+	}{
+		{
+			name:     "missing file",
+			server:   &downloadServer{failures: 1, status: http.StatusNotFound},
+			requests: 1,
+			check: func(err error) bool {
+				var statusErr *DownloadStatusError
+				return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+			},
+		},
+		{
+			name:     "out of retries",
+			server:   &downloadServer{failures: 10, status: http.StatusBadGateway},
+			requests: 4,
+			check: func(err error) bool {
+				var statusErr *DownloadStatusError
+				return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusBadGateway
+			},
+		},
+		{
+			name:     "file changed on the server",
+			server:   &downloadServer{},
+			offset:   int64(len(testDownload)) + 100,
+			requests: 1,
+			check: func(err error) bool {
+				return errors.Is(err, errResumeFailed)
+			},
+		},
+		{
+			name:     "resumed from the wrong position",
+			server:   &downloadServer{wrongStart: true},
+			offset:   100,
+			requests: 1,
+			check: func(err error) bool {
+				return errors.Is(err, errResumeFailed)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestResumableReader(t, test.server, test.offset)
+			if err := r.connect(); !test.check(err) {
+				t.Errorf("connect() = %v", err)
+			}
+
+			if len(test.server.ranges) != test.requests {
+				t.Errorf("made %d requests, want %d", len(test.server.ranges), test.requests)
+			}
+		})
+	}
+}
@@ -6,17 +6,15 @@ GPLv3 License, see the LICENSE file for more information.
 package core
 
 import (
-	"crypto"
+	"context"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
-	"github.com/cheggaaa/pb/v3"
 	"github.com/spf13/viper"
 )
 
@@ -45,6 +43,33 @@ func GetCustomLocation(arg string) string {
 	return arg
 }
 
+/*
+GetCustomLocations returns the paths of every custom location, in the order of their keywords.
+Example:
+
+	locations := GetCustomLocations()
+	fmt.Println(locations[0]) // ~/.local/share/lutris/runners/wine/
+
+Returns:
+
+	[]string: The paths of the custom locations.
+*/
+func GetCustomLocations() []string {
+	customLocations := viper.GetStringMapString("app.customlocations")
+
+	keys := make([]string, 0, len(customLocations))
+	for key := range customLocations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	locations := make([]string, 0, len(keys))
+	for _, key := range keys {
+		locations = append(locations, customLocations[key])
+	}
+	return locations
+}
+
 /*
 UsePath returns the path with sane changes to it.
 Arguments:
@@ -133,6 +158,7 @@ func GetUserTemp() (string, error) {
 
 /*
 DownloadFile downloads the file from the given URL, following redirects if needed. The final file will be put at the given path
+Failed downloads are retried and resumed where possible, and anything other than the file itself (eg. a 404 page) is rejected.
 Arguments:
 
 	path<string>: The path to download the file to.
@@ -148,145 +174,34 @@ Returns:
 	error: An error if one occurs.
 */
 func DownloadFile(path, url string) (os.FileInfo, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
-		if err != nil {
-			return nil, err
-		}
-		Debug("DownloadFile: Created directory: " + filepath.Dir(path))
-	}
-
-	// Create the file
-	out, err := os.Create(path)
-	if err != nil {
-		return nil, err
-	}
-
-	defer out.Close()
-
-	// Fetch the file from the URL
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-
 	Debug("DownloadFile: Downloading file from: " + url)
 
-	// Set up a progress bar
-	tmpl := `{{ cycle . "⠃" "⠆" "⠤" "⠰" "⠘" "⠉" }} Installing {{string . "src"}} [{{percent .}} | {{speed . "%s/s"}} | {{ rtime .}}]`
-	bar := pb.ProgressBarTemplate(tmpl).Start64(resp.ContentLength).Set("src", strings.Split(url, "/")[len(strings.Split(url, "/"))-1])
-	reader := bar.NewProxyReader(resp.Body)
-
-	defer resp.Body.Close()
-
-	// Write the data to the file
-	_, err = io.Copy(out, reader)
-	if err != nil {
-		return nil, err
-	}
-
-	// Check if the file is valid
-	if _, err := os.Stat(path); err != nil {
-		return nil, err
-	}
-
-	Debug("DownloadFile: Downloaded file to: " + path)
-
-	bar.Finish()
-
-	// Get the downloaded file and return it
-	return os.Stat(path)
-}
-
-/*
-ExtractTar extracts the given tar file to the given path using gnu-tar.
-Arguments:
-
-	tarPath<string>: The path to the tar file.
-	extractPath<string>: The path to extract the tar file to.
-
-Example:
-
-	err := ExtractTar("$HOME/Downloads/file.tar.gz", "$HOME/Downloads/")
-
-Returns:
-
-	error: An error if one occurs.
-*/
-func ExtractTar(tarPath, extractPath string) error {
-
-	// If path doesnt exist create it
-	if _, err := os.Stat(extractPath); os.IsNotExist(err) {
-		err := os.MkdirAll(filepath.Dir(extractPath), os.ModePerm)
-		if err != nil {
-			return err
-		}
-
-		Debug("DownloadFile: Created directory: " + filepath.Dir(extractPath))
-	}
-
-	cmd := exec.Command("tar", "-xf", tarPath, "-C", extractPath)
-	err := cmd.Start()
-
-	if err != nil {
-		return err
-	}
-
-	err = cmd.Wait()
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	name := strings.Split(url, "/")[len(strings.Split(url, "/"))-1]
+	return fetch(context.Background(), openURLFrom(url, name), path, name, nil)
 }
 
 /*
-Tries to match a given file's sha512sum against the given sum file
+DownloadAsset downloads the given release asset from its source. The final file will be put at the given path
+Assets served over HTTP are retried and resumed the same way as DownloadFile.
 Arguments:
 
-	filePath<string>: The path to the file to check.
-	sumPath<string>: The path to the sum file.
+	path<string>: The path to download the file to.
+	source<Source>: The source that published the asset.
+	asset<*Asset>: The asset to download.
 
 Example:
 
-	match, err := CheckSum("$HOME/Downloads/file.tar.gz", "$HOME/Downloads/file.tar.gz.sha512sum")
-	fmt.Println(match) // true
+	file, err := DownloadAsset("$HOME/Downloads/file.tar.gz", source, asset)
 
 Returns:
 
-	bool: Whether or not the file matches the sum.
+	os.FileInfo: The file that was downloaded.
 	error: An error if one occurs.
 */
-func MatchChecksum(filePath, sumPath string) (bool, error) {
-	// Get the sum of the file with crypto inbuilt
-	h := crypto.SHA512.New()
-	f, err := os.Open(filePath)
-	if err != nil {
-		return false, err
-	}
-
-	defer f.Close()
-
-	if _, err := io.Copy(h, f); err != nil {
-		return false, err
-	}
-
-	// Get the sum of the file in the sum file
-	sum, err := ioutil.ReadFile(sumPath)
-	if err != nil {
-		return false, err
-	}
-
-	// Check all lines for the files sum
-	for _, line := range strings.Split(string(sum), "\n") {
-		Debug("MatchChecksum: Attempting to match checksum for files: " + filePath + " and " + sumPath)
-		if strings.HasPrefix(line, fmt.Sprintf("%x", h.Sum(nil))) {
-			return true, nil
-		}
-	}
+func DownloadAsset(path string, source Source, asset *Asset) (os.FileInfo, error) {
+	Debug("DownloadAsset: Downloading " + asset.Name + " from: " + source.String())
 
-	return false, nil
+	return fetch(context.Background(), openAssetFrom(source, asset), path, asset.Name, nil)
 }
 
 /*
@@ -348,3 +263,82 @@ func HumanReadableBytes(bytes int64) (int64, string) {
 		return bytes / (1024 * 1024 * 1024), "GB"
 	}
 }
+
+/*
+ParseByteSize parses a human readable amount of bytes, using the same 1024 based units as HumanReadableBytes.
+Arguments:
+
+	size<string>: The size to parse, a number optionally followed by B, KB, MB, GB or TB (eg. "500MB" or "1.5GB").
+
+Example:
+
+	bytes, err := ParseByteSize("4MB")
+	fmt.Println(bytes) // 4194304
+
+Returns:
+
+	int64: The amount of bytes.
+	error: An error if the size is invalid.
+*/
+func ParseByteSize(size string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"TB", 1024 * 1024 * 1024 * 1024},
+		{"GB", 1024 * 1024 * 1024},
+		{"MB", 1024 * 1024},
+		{"KB", 1024},
+		{"T", 1024 * 1024 * 1024 * 1024},
+		{"G", 1024 * 1024 * 1024},
+		{"M", 1024 * 1024},
+		{"K", 1024},
+		{"B", 1},
+	}
+
+	number, multiplier := strings.ToUpper(strings.TrimSpace(size)), 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a value such as 500MB or 2GB", size)
+	}
+
+	return int64(value * multiplier), nil
+}
+
+/*
+writeFileAtomic replaces the file at the given path with the given contents, so that readers never see a partially written file.
+Arguments:
+
+	path<string>: The path to the file.
+	contents<[]byte>: The new contents of the file.
+
+Returns:
+
+	error: An error if one occurs.
+*/
+func writeFileAtomic(path string, contents []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(contents)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
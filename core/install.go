@@ -0,0 +1,432 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Installs are extracted into a staging directory and old versions are moved aside into a backup directory. Both are kept in
+// a hidden work directory inside of the install directory, so that moving them into place is a rename on the same filesystem
+// while Steam and other launchers (which only look one level deep) never mistake them for installed runners.
+const (
+	workDirName      = ".proto"
+	stagingDirPrefix = "staging-"
+	backupDirPrefix  = "backup-"
+)
+
+/*
+InstallArchive installs the given archive into the given directory without ever leaving a partial install behind.
+The archive is extracted to a staging directory and only renamed into place once it has been fully extracted, any existing
+version is kept until then and restored if anything goes wrong, including the install being interrupted with Ctrl-C.
+Arguments:
+
+	archivePath<string>: The path to the archive.
+	installDir<string>: The directory to install the runner to.
+	hash<io.Writer>: Receives the contents of the archive as it is extracted (eg. a ChecksumVerifier), or nil.
+	verify<func() error>: Called once the archive has been extracted and before anything is installed, returning an error aborts the install. Can be nil.
+
+Example:
+
+	paths, err := InstallArchive("/tmp/proto/GE-Proton8-25.tar.gz", "$HOME/.steam/root/compatibilitytools.d/", nil, nil)
+
+Returns:
+
+	[]string: The paths of the installed runners, one for every top level entry in the archive.
+	error: An error if one occurs, in which case the install directory is left as it was.
+*/
+func InstallArchive(archivePath, installDir string, hash io.Writer, verify func() error) ([]string, error) {
+	format, err := getInstallFormat(filepath.Base(archivePath))
+	if err != nil {
+		return nil, err
+	}
+
+	return installStaged(installDir, filepath.Base(archivePath), func(ctx context.Context, staging string) error {
+		if format.stream == nil {
+			if hash != nil {
+				if err := copyFile(hash, archivePath); err != nil {
+					return err
+				}
+			}
+
+			return format.extract(ctx, archivePath, staging)
+		}
+
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		return streamArchive(ctx, format, file, hash, staging)
+	}, verify)
+}
+
+/*
+DownloadAndInstall downloads the given release asset and installs it at the same time, extracting the archive into the staging
+directory as it arrives rather than reading it again once the download has finished. The archive is also saved to the given path
+and written to hash as it is downloaded, so that verify can check it before anything is installed.
+Archives that can't be extracted while downloading (zip files) are downloaded first and then installed with InstallArchive.
+Arguments:
+
+	source<Source>: The source that published the asset.
+	asset<*Asset>: The runner archive.
+	path<string>: The path to save the archive to.
+	installDir<string>: The directory to install the runner to.
+	hash<io.Writer>: Receives the contents of the archive as it is downloaded (eg. a ChecksumVerifier), or nil.
+	verify<func() error>: Called once the archive has been downloaded and extracted and before anything is installed, returning an error aborts the install. Can be nil.
+
+Example:
+
+	paths, err := DownloadAndInstall(source, asset, "/tmp/proto/GE-Proton8-25.tar.gz", "$HOME/.steam/root/compatibilitytools.d/", verifier, check)
+
+Returns:
+
+	[]string: The paths of the installed runners.
+	error: An error if one occurs, in which case the install directory is left as it was.
+*/
+func DownloadAndInstall(source Source, asset *Asset, path, installDir string, hash io.Writer, verify func() error) ([]string, error) {
+	Debug("DownloadAndInstall: Installing " + asset.Name + " from: " + source.String())
+
+	return downloadAndInstall(openAssetFrom(source, asset), asset.Name, path, installDir, hash, verify)
+}
+
+/*
+DownloadURLAndInstall is DownloadAndInstall for an archive at an arbitrary URL rather than from a source.
+Arguments:
+
+	url<string>: The URL of the archive.
+	path<string>: The path to save the archive to, its file name is used as the name of the archive.
+	installDir<string>: The directory to install the runner to.
+	hash<io.Writer>: Receives the contents of the archive as it is downloaded (eg. a ChecksumVerifier), or nil.
+	verify<func() error>: Called once the archive has been downloaded and extracted and before anything is installed, returning an error aborts the install. Can be nil.
+
+Example:
+
+	paths, err := DownloadURLAndInstall("https://example.com/GE-Proton8-25.tar.gz", "/tmp/proto/GE-Proton8-25.tar.gz", "$HOME/.steam/root/compatibilitytools.d/", nil, nil)
+
+Returns:
+
+	[]string: The paths of the installed runners.
+	error: An error if one occurs, in which case the install directory is left as it was.
+*/
+func DownloadURLAndInstall(url, path, installDir string, hash io.Writer, verify func() error) ([]string, error) {
+	Debug("DownloadURLAndInstall: Installing from: " + url)
+
+	return downloadAndInstall(openURLFrom(url, filepath.Base(path)), filepath.Base(path), path, installDir, hash, verify)
+}
+
+/*
+downloadAndInstall downloads the archive opened by open to the given path, extracting it into a staging directory as it arrives.
+Arguments:
+
+	open<openFunc>: Opens the archive.
+	name<string>: The file name of the archive.
+	path<string>: The path to save the archive to.
+	installDir<string>: The directory to install the runner to.
+	hash<io.Writer>: Receives the contents of the archive as it is downloaded, or nil.
+	verify<func() error>: Called before anything is installed, or nil.
+
+Returns:
+
+	[]string: The paths of the installed runners.
+	error: An error if one occurs.
+*/
+func downloadAndInstall(open openFunc, name, path, installDir string, hash io.Writer, verify func() error) ([]string, error) {
+	format, err := getInstallFormat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sources don't always list the size of their assets, so check the space needed again once the download says how big it is.
+	open = checkDownloadSpace(open, name, path, installDir)
+
+	if format.stream == nil {
+		if _, err := fetch(context.Background(), open, path, name, nil); err != nil {
+			return nil, err
+		}
+
+		return InstallArchive(path, installDir, hash, verify)
+	}
+
+	return installStaged(installDir, name, func(ctx context.Context, staging string) error {
+		_, err := fetch(ctx, open, path, name, func(r io.Reader) error {
+			return streamArchive(ctx, format, r, hash, staging)
+		})
+		return err
+	}, verify)
+}
+
+/*
+checkDownloadSpace wraps an opener so the download is refused before anything is written if the archive, or the files extracted
+from it, won't fit. Nothing is checked if the server doesn't say how big the archive is.
+Arguments:
+
+	open<openFunc>: Opens the archive.
+	name<string>: The file name of the archive.
+	path<string>: The path the archive is saved to.
+	installDir<string>: The directory the runner is installed to.
+
+Returns:
+
+	openFunc: The opener.
+*/
+func checkDownloadSpace(open openFunc, name, path, installDir string) openFunc {
+	return func(ctx context.Context, offset int64) (io.ReadCloser, int64, int64, error) {
+		body, size, start, err := open(ctx, offset)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		if size < 0 {
+			Debug("checkDownloadSpace: Skipping the free space check, the size of " + name + " is unknown")
+			return body, size, start, nil
+		}
+
+		err = CheckFreeSpace(
+			SpaceRequirement{Path: filepath.Dir(path), Size: size - start},
+			SpaceRequirement{Path: installDir, Size: EstimateInstallSize(name, size)},
+		)
+		if err != nil {
+			body.Close()
+			return nil, 0, 0, err
+		}
+
+		return body, size, start, nil
+	}
+}
+
+/*
+getInstallFormat returns the archive format of the given file name, or an error if it isn't a supported archive.
+Arguments:
+
+	name<string>: The file name of the archive.
+
+Returns:
+
+	*archiveFormat: The archive format.
+	error: An error if the file is not a supported archive.
+*/
+func getInstallFormat(name string) (*archiveFormat, error) {
+	format := getArchiveFormat(name)
+	if format == nil {
+		return nil, fmt.Errorf("%s is not a supported archive, expected one of: %s", name, strings.Join(GetArchiveExtensions(), ", "))
+	}
+
+	return format, nil
+}
+
+/*
+streamArchive extracts the archive read from the given stream, passing everything read to hash, including any data at the end
+of the stream that the format doesn't need (eg. the padding after a tarball).
+Arguments:
+
+	ctx<context.Context>: The context of the extraction.
+	format<*archiveFormat>: The format of the archive, which must support streaming.
+	r<io.Reader>: The archive stream.
+	hash<io.Writer>: Receives the contents of the archive, or nil.
+	extractPath<string>: The path to extract the archive to.
+
+Returns:
+
+	error: An error if one occurs.
+*/
+func streamArchive(ctx context.Context, format *archiveFormat, r io.Reader, hash io.Writer, extractPath string) error {
+	if hash != nil {
+		r = io.TeeReader(r, hash)
+	}
+
+	if err := format.stream(ctx, r, extractPath); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+/*
+installStaged runs the given extraction into a staging directory in the install directory's work directory, and moves the result into place
+once it has finished and passed verification. Interrupts (Ctrl-C) are handled for the whole install so they roll back instead of
+killing the process midway.
+Arguments:
+
+	installDir<string>: The directory to install the runner to.
+	name<string>: The file name of the archive, for messages.
+	extract<func(context.Context, string) error>: Extracts the archive into the given staging directory.
+	verify<func() error>: Called before anything is installed, returning an error aborts the install. Can be nil.
+
+Returns:
+
+	[]string: The paths of the installed entries.
+	error: An error if one occurs, in which case the install directory is left as it was.
+*/
+func installStaged(installDir, name string, extract func(ctx context.Context, staging string) error, verify func() error) ([]string, error) {
+	workDir := filepath.Join(installDir, workDirName)
+	if err := os.MkdirAll(workDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	// The work directory is only needed during the install, so don't leave it behind unless something in it couldn't be cleaned up.
+	defer os.Remove(workDir)
+
+	recoverInterruptedInstalls(installDir)
+
+	// Handle interrupts ourselves for the rest of the install, so they roll back instead of killing the process midway.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	staging, err := os.MkdirTemp(workDir, stagingDirPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(staging)
+
+	Debug("installStaged: Staging install in " + staging)
+
+	if err := extract(ctx, staging); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("the install was interrupted, no changes were made")
+		}
+		return nil, err
+	}
+
+	// Nothing is installed until the archive has passed verification.
+	if verify != nil {
+		if err := verify(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Make sure the extraction actually produced something before replacing anything with it.
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s did not contain any files, no changes were made", name)
+	}
+
+	return commitStaged(ctx, staging, installDir, entries)
+}
+
+/*
+commitStaged moves every staged entry into the install directory, moving aside anything it replaces.
+The replaced entries are only deleted once everything is in place, and are restored if any step fails.
+Arguments:
+
+	ctx<context.Context>: The context of the install, the commit is rolled back if it is cancelled.
+	staging<string>: The staging directory.
+	installDir<string>: The directory to install to.
+	entries<[]os.DirEntry>: The staged entries.
+
+Returns:
+
+	[]string: The paths of the installed entries.
+	error: An error if one occurs.
+*/
+func commitStaged(ctx context.Context, staging, installDir string, entries []os.DirEntry) ([]string, error) {
+	var committed []string
+	backups := map[string]string{}
+
+	rollback := func(cause error) ([]string, error) {
+		Debug("commitStaged: Rolling back: " + cause.Error())
+
+		for _, name := range committed {
+			os.RemoveAll(filepath.Join(installDir, name))
+		}
+
+		for name, backup := range backups {
+			if err := os.Rename(backup, filepath.Join(installDir, name)); err != nil {
+				return nil, fmt.Errorf("%w (unable to restore the previous %s, it was left at %s: %v)", cause, name, backup, err)
+			}
+		}
+
+		return nil, cause
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return rollback(fmt.Errorf("the install was interrupted, no changes were made"))
+		}
+
+		name := entry.Name()
+		live := filepath.Join(installDir, name)
+
+		// Keep the old version around until the new one is completely in place.
+		if _, err := os.Lstat(live); err == nil {
+			backup := filepath.Join(installDir, workDirName, backupDirPrefix+name)
+			if err := os.RemoveAll(backup); err != nil {
+				return rollback(err)
+			}
+
+			if err := os.Rename(live, backup); err != nil {
+				return rollback(err)
+			}
+
+			Debug("commitStaged: Moved previous " + name + " to " + backup)
+			backups[name] = backup
+		}
+
+		if err := os.Rename(filepath.Join(staging, name), live); err != nil {
+			return rollback(err)
+		}
+
+		committed = append(committed, name)
+	}
+
+	// The new version is complete, so the old one is no longer needed.
+	for name, backup := range backups {
+		if err := os.RemoveAll(backup); err != nil {
+			Debug("commitStaged: Unable to remove previous " + name + ": " + err.Error())
+		}
+	}
+
+	paths := make([]string, 0, len(committed))
+	for _, name := range committed {
+		paths = append(paths, filepath.Join(installDir, name))
+	}
+
+	return paths, nil
+}
+
+/*
+recoverInterruptedInstalls cleans up after an install that was killed before it could finish or roll back.
+Leftover staging directories are removed, and backups are restored if the version they were replacing never made it into place.
+Arguments:
+
+	installDir<string>: The install directory.
+*/
+func recoverInterruptedInstalls(installDir string) {
+	workDir := filepath.Join(installDir, workDirName)
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(workDir, entry.Name())
+
+		switch {
+		case strings.HasPrefix(entry.Name(), stagingDirPrefix):
+			Debug("recoverInterruptedInstalls: Removing leftover staging directory " + path)
+			os.RemoveAll(path)
+		case strings.HasPrefix(entry.Name(), backupDirPrefix):
+			original := filepath.Join(installDir, strings.TrimPrefix(entry.Name(), backupDirPrefix))
+			if _, err := os.Lstat(original); os.IsNotExist(err) {
+				Debug("recoverInterruptedInstalls: Restoring " + original + " from " + path)
+				os.Rename(path, original)
+			} else {
+				Debug("recoverInterruptedInstalls: Removing leftover backup " + path)
+				os.RemoveAll(path)
+			}
+		}
+	}
+}
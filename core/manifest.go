@@ -0,0 +1,398 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// installManifestName is the file in the data directory that records every runner installed by Proto.
+const installManifestName = "installs.json"
+
+// InstalledRunner records where a runner installed by Proto came from.
+type InstalledRunner struct {
+	Source       string    `json:"source"`
+	Tag          string    `json:"tag"`
+	Asset        string    `json:"asset"`
+	Checksum     string    `json:"checksum"`
+	Path         string    `json:"path"`
+	InstalledAt  time.Time `json:"installed_at"`
+	ProtoVersion string    `json:"proto_version"`
+}
+
+/*
+Tracked returns whether the runner is recorded in the install manifest, rather than only matched to its source by its directory name.
+Example:
+
+	fmt.Println(runner.Tracked()) // true
+
+Returns:
+
+	bool: Whether the runner is in the install manifest.
+*/
+func (r *InstalledRunner) Tracked() bool {
+	// Every install recorded in the manifest has an install time.
+	return !r.InstalledAt.IsZero()
+}
+
+/*
+GetDataDir returns the directory Proto keeps its state in, following the XDG base directory specification.
+Example:
+
+	dir := GetDataDir()
+	fmt.Println(dir) // $HOME/.local/share/proto
+
+Returns:
+
+	string: The path to the data directory.
+*/
+func GetDataDir() string {
+	if dataDir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dataDir) {
+		return filepath.Join(dataDir, "proto")
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".local", "share", "proto")
+}
+
+/*
+ListInstalledRunners returns every runner recorded in the install manifest, sorted by their install path.
+Example:
+
+	runners, err := ListInstalledRunners()
+	fmt.Println(runners[0].Tag) // GE-Proton8-25
+
+Returns:
+
+	[]*InstalledRunner: The installed runners.
+	error: Any errors that occur.
+*/
+func ListInstalledRunners() ([]*InstalledRunner, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(GetDataDir(), installManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var runners []*InstalledRunner
+	if err := json.Unmarshal(contents, &runners); err != nil {
+		return nil, fmt.Errorf("the install manifest at %s is corrupt: %w", filepath.Join(GetDataDir(), installManifestName), err)
+	}
+
+	sort.SliceStable(runners, func(i, j int) bool {
+		return runners[i].Path < runners[j].Path
+	})
+
+	return runners, nil
+}
+
+/*
+RecordInstall adds the given runner to the install manifest, replacing anything previously recorded at the same path.
+Arguments:
+
+	runner<*InstalledRunner>: The installed runner, its install time and Proto version are filled in if they are empty.
+
+Example:
+
+	err := RecordInstall(&InstalledRunner{Source: "GloriousEggroll/proton-ge-custom", Tag: "GE-Proton8-25", Path: path})
+
+Returns:
+
+	error: Any errors that occur.
+*/
+func RecordInstall(runner *InstalledRunner) error {
+	runner.Path = manifestPath(runner.Path)
+	if runner.InstalledAt.IsZero() {
+		runner.InstalledAt = time.Now()
+	}
+	if runner.ProtoVersion == "" {
+		runner.ProtoVersion = Version
+	}
+
+	runners, err := ListInstalledRunners()
+	if err != nil {
+		return err
+	}
+
+	kept := runners[:0]
+	for _, existing := range runners {
+		if existing.Path != runner.Path {
+			kept = append(kept, existing)
+		}
+	}
+
+	Debug("RecordInstall: Recording " + runner.Tag + " at " + runner.Path)
+	return writeInstallManifest(append(kept, runner))
+}
+
+/*
+RemoveInstall removes the runner installed at the given path from the install manifest, if it is recorded there.
+Arguments:
+
+	path<string>: The path of the runner.
+
+Example:
+
+	err := RemoveInstall("$HOME/.steam/root/compatibilitytools.d/GE-Proton8-25")
+
+Returns:
+
+	error: Any errors that occur.
+*/
+func RemoveInstall(path string) error {
+	runners, err := ListInstalledRunners()
+	if err != nil {
+		return err
+	}
+
+	path = manifestPath(path)
+	kept := runners[:0]
+	for _, existing := range runners {
+		if existing.Path != path {
+			kept = append(kept, existing)
+		}
+	}
+
+	if len(kept) == len(runners) {
+		return nil
+	}
+
+	Debug("RemoveInstall: Removing " + path)
+	return writeInstallManifest(kept)
+}
+
+/*
+manifestPath returns the absolute form of the given path, which is how runners are recorded in the install manifest.
+Arguments:
+
+	path<string>: The path of a runner.
+
+Returns:
+
+	string: The absolute path.
+*/
+func manifestPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return filepath.Clean(path)
+}
+
+/*
+writeInstallManifest atomically replaces the install manifest with the given runners.
+Arguments:
+
+	runners<[]*InstalledRunner>: The installed runners.
+
+Returns:
+
+	error: Any errors that occur.
+*/
+func writeInstallManifest(runners []*InstalledRunner) error {
+	if err := os.MkdirAll(GetDataDir(), 0700); err != nil {
+		return err
+	}
+
+	contents, err := json.MarshalIndent(runners, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(GetDataDir(), installManifestName), contents)
+}
+
+// untrackedReleaseLimit is how many of a source's newest releases are matched against runners that aren't in the install
+// manifest, such as those installed before it existed.
+const untrackedReleaseLimit = 50
+
+// SourceInstalls are the runners in an install directory that came from the same source.
+type SourceInstalls struct {
+	SourceIndex int
+	Source      Source
+
+	// Releases are the newest releases of the source, newest first.
+	Releases []*Release
+
+	// Runners are the runners installed from the source, newest release first. Runners that aren't in the install manifest
+	// are matched by their directory name being the tag of a release, and don't have an asset, checksum or install time.
+	Runners []*InstalledRunner
+
+	// Err is set if the releases of a source with runners in the install manifest couldn't be fetched.
+	Err error
+}
+
+/*
+Latest returns the newest release of the source, or nil if it has none.
+Returns:
+
+	*Release: The newest release.
+*/
+func (s *SourceInstalls) Latest() *Release {
+	if len(s.Releases) == 0 {
+		return nil
+	}
+
+	return s.Releases[0]
+}
+
+/*
+Has returns whether the given tag is one of the installed runners.
+Arguments:
+
+	tag<string>: The tag of the release.
+
+Returns:
+
+	bool: Whether the release is installed.
+*/
+func (s *SourceInstalls) Has(tag string) bool {
+	for _, runner := range s.Runners {
+		if runner.Tag == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+FindSourceInstalls works out which of the configured sources the runners in the given directory were installed from.
+Runners recorded in the install manifest are matched by their source, anything else is matched by its directory name
+against the tags of each source's newest releases. Sources that can't be reached are skipped unless the manifest says
+they have runners in the directory.
+Arguments:
+
+	dir<string>: The install directory.
+
+Example:
+
+	installs, err := FindSourceInstalls("$HOME/.steam/root/compatibilitytools.d/")
+	fmt.Println(installs[0].Latest().TagName) // GE-Proton8-25
+
+Returns:
+
+	[]*SourceInstalls: The sources that have runners in the directory, in the order they are configured.
+	error: Any errors that occur.
+*/
+func FindSourceInstalls(dir string) ([]*SourceInstalls, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	runners, err := ListInstalledRunners()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only runners that are still in the directory count.
+	tracked := map[string]*InstalledRunner{}
+	for _, runner := range runners {
+		if filepath.Dir(runner.Path) == manifestPath(dir) {
+			tracked[filepath.Base(runner.Path)] = runner
+		}
+	}
+
+	var untracked []string
+	present := map[string]bool{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		present[entry.Name()] = true
+		if tracked[entry.Name()] == nil {
+			untracked = append(untracked, entry.Name())
+		}
+	}
+
+	var result []*SourceInstalls
+	claimed := map[string]bool{}
+	for i := range viper.GetStringSlice("app.sources") {
+		source, err := GetSource(i)
+		if err != nil {
+			Debug("FindSourceInstalls: Skipping source " + fmt.Sprint(i+1) + ": " + err.Error())
+			continue
+		}
+
+		installs := &SourceInstalls{SourceIndex: i, Source: source}
+		for name, runner := range tracked {
+			if present[name] && !claimed[name] && runner.Source == source.String() {
+				installs.Runners = append(installs.Runners, runner)
+				claimed[name] = true
+			}
+		}
+
+		installs.Releases, installs.Err = GetReleases(i, untrackedReleaseLimit)
+		if installs.Err != nil {
+			if len(installs.Runners) == 0 {
+				Debug("FindSourceInstalls: Skipping " + source.String() + ": " + installs.Err.Error())
+				continue
+			}
+
+			installs.sortRunners()
+			result = append(result, installs)
+			continue
+		}
+
+		for _, release := range installs.Releases {
+			for _, name := range untracked {
+				if name == release.TagName && !claimed[name] {
+					installs.Runners = append(installs.Runners, &InstalledRunner{
+						Source: source.String(),
+						Tag:    release.TagName,
+						Path:   manifestPath(filepath.Join(dir, name)),
+					})
+					claimed[name] = true
+				}
+			}
+		}
+
+		if len(installs.Runners) == 0 {
+			continue
+		}
+
+		installs.sortRunners()
+		result = append(result, installs)
+	}
+
+	return result, nil
+}
+
+/*
+sortRunners sorts the installed runners newest release first. Runners whose release is older than the fetched releases
+go last, newest install first.
+*/
+func (s *SourceInstalls) sortRunners() {
+	position := map[string]int{}
+	for i, release := range s.Releases {
+		position[release.TagName] = i
+	}
+
+	rank := func(runner *InstalledRunner) int {
+		if i, ok := position[runner.Tag]; ok {
+			return i
+		}
+		return len(s.Releases)
+	}
+
+	sort.SliceStable(s.Runners, func(i, j int) bool {
+		if rank(s.Runners[i]) != rank(s.Runners[j]) {
+			return rank(s.Runners[i]) < rank(s.Runners[j])
+		}
+		return s.Runners[i].InstalledAt.After(s.Runners[j].InstalledAt)
+	})
+}
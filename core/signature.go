@@ -0,0 +1,519 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/blake2b"
+)
+
+// signatureScheme is a kind of detached signature that assets can be verified with.
+type signatureScheme struct {
+	name       string
+	option     string
+	extensions []string
+	parseKey   func(data []byte) (interface{}, error)
+	verify     func(key interface{}, filePath string, signature []byte) error
+}
+
+// signatureSchemes are the supported signature schemes, each source lists its trusted keys for a scheme in the option of the scheme.
+// The ".sig" extension is shared by GPG and cosign, so both are tried.
+var signatureSchemes = []signatureScheme{
+	{"minisign", "minisign-key", []string{".minisig"}, parseMinisignKey, verifyMinisign},
+	{"gpg", "gpg-key", []string{".asc", ".sig", ".gpg"}, parseGPGKey, verifyGPG},
+	{"cosign", "cosign-key", []string{".sig", ".bundle", ".sigstore", ".sigstore.json"}, parseCosignKey, verifyCosign},
+}
+
+// TrustPolicy holds the public keys a source trusts to sign its releases.
+type TrustPolicy struct {
+	keys map[*signatureScheme][]interface{}
+}
+
+/*
+newTrustPolicy creates a trust policy from the options of a source entry.
+Every key option holds either the path to a key file or, for minisign, the public key itself.
+Arguments:
+
+	options<url.Values>: The options of the source entry.
+
+Returns:
+
+	*TrustPolicy: The trust policy.
+	error: An error if any of the keys can't be read.
+*/
+func newTrustPolicy(options url.Values) (*TrustPolicy, error) {
+	policy := &TrustPolicy{keys: map[*signatureScheme][]interface{}{}}
+
+	for i := range signatureSchemes {
+		scheme := &signatureSchemes[i]
+		for _, value := range options[scheme.option] {
+			data, err := readKeyOption(value)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read %s: %w", scheme.option, err)
+			}
+
+			key, err := scheme.parseKey(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %s: %w", scheme.option, value, err)
+			}
+
+			policy.keys[scheme] = append(policy.keys[scheme], key)
+		}
+	}
+
+	return policy, nil
+}
+
+/*
+GetTrustPolicy returns the trust policy configured for the source at the specified source index.
+Arguments:
+
+	entryIndex<int>: The index of the source.
+
+Example:
+
+	policy, err := GetTrustPolicy(0)
+
+Returns:
+
+	*TrustPolicy: The trust policy of the source.
+	error: Any errors that occur.
+*/
+func GetTrustPolicy(entryIndex int) (*TrustPolicy, error) {
+	sources := viper.GetStringSlice("app.sources")
+	if entryIndex < 0 || entryIndex >= len(sources) {
+		return nil, fmt.Errorf("there is no source at index %d", entryIndex+1)
+	}
+
+	_, _, options, err := SplitSourceEntry(sources[entryIndex])
+	if err != nil {
+		return nil, err
+	}
+
+	return newTrustPolicy(options)
+}
+
+/*
+Enabled returns whether the source has any trusted keys, meaning its releases must be signed.
+Returns:
+
+	bool: Whether signatures are required.
+*/
+func (p *TrustPolicy) Enabled() bool {
+	return len(p.keys) > 0
+}
+
+/*
+IsSignatureFile returns whether the given file name is a detached signature of a supported scheme.
+Arguments:
+
+	name<string>: The file name.
+
+Returns:
+
+	bool: Whether the file is a signature.
+*/
+func IsSignatureFile(name string) bool {
+	for _, scheme := range signatureSchemes {
+		for _, extension := range scheme.extensions {
+			if strings.HasSuffix(strings.ToLower(name), extension) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+GetSignatureAssets returns the detached signatures of the given asset, which are named after it with a signature extension (eg. "runner.tar.gz.minisig").
+Arguments:
+
+	assets<[]*Asset>: The assets of the release.
+	signed<*Asset>: The asset to find the signatures of.
+
+Example:
+
+	sigs := GetSignatureAssets(release.Assets, tar)
+
+Returns:
+
+	[]*Asset: The signatures of the asset.
+*/
+func GetSignatureAssets(assets []*Asset, signed *Asset) []*Asset {
+	var result []*Asset
+	for _, asset := range assets {
+		if strings.HasPrefix(asset.Name, signed.Name+".") && IsSignatureFile(asset.Name) {
+			result = append(result, asset)
+		}
+	}
+
+	return result
+}
+
+/*
+VerifySignatures checks the given file against its detached signatures, passing if any of them was made by a trusted key.
+Arguments:
+
+	filePath<string>: The path to the signed file.
+	signaturePaths<[]string>: The paths to the signatures of the file.
+
+Example:
+
+	err := policy.VerifySignatures("/tmp/proto/runner.tar.gz", []string{"/tmp/proto/runner.tar.gz.minisig"})
+
+Returns:
+
+	error: An error explaining why every signature was rejected, or nil if the file is signed by a trusted key.
+*/
+func (p *TrustPolicy) VerifySignatures(filePath string, signaturePaths []string) error {
+	var reasons []string
+
+	for _, signaturePath := range signaturePaths {
+		signature, err := ioutil.ReadFile(signaturePath)
+		if err != nil {
+			return err
+		}
+
+		for i := range signatureSchemes {
+			scheme, keys := &signatureSchemes[i], p.keys[&signatureSchemes[i]]
+			if len(keys) == 0 || !hasAnySuffix(strings.ToLower(signaturePath), scheme.extensions) {
+				continue
+			}
+
+			for _, key := range keys {
+				Debug("VerifySignatures: Checking " + signaturePath + " with a " + scheme.name + " key")
+
+				err := scheme.verify(key, filePath, signature)
+				if err == nil {
+					Debug("VerifySignatures: " + filePath + " has a valid " + scheme.name + " signature")
+					return nil
+				}
+
+				reasons = append(reasons, fmt.Sprintf("%s (%s): %v", filepath.Base(signaturePath), scheme.name, err))
+			}
+		}
+	}
+
+	if len(reasons) == 0 {
+		return fmt.Errorf("none of the signatures use a scheme the source has trusted keys for")
+	}
+
+	return fmt.Errorf("%s", strings.Join(reasons, "; "))
+}
+
+/*
+readKeyOption reads the key given in a source option, which is either a path to a key file or the key itself.
+Arguments:
+
+	value<string>: The value of the option.
+
+Returns:
+
+	[]byte: The key data.
+	error: An error if the key file can't be read.
+*/
+func readKeyOption(value string) ([]byte, error) {
+	path := UsePath(value, false)
+	if strings.HasPrefix(path, "/") || strings.HasPrefix(path, ".") {
+		return ioutil.ReadFile(path)
+	}
+
+	return []byte(value), nil
+}
+
+/*
+parseMinisignKey parses a minisign public key, either on its own or as the contents of a minisign .pub file.
+Arguments:
+
+	data<[]byte>: The key data.
+
+Returns:
+
+	interface{}: The parsed key.
+	error: An error if the key is invalid.
+*/
+func parseMinisignKey(data []byte) (interface{}, error) {
+	var encoded string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "untrusted comment:") {
+			encoded = line
+			break
+		}
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != 42 || string(raw[:2]) != "Ed" {
+		return nil, fmt.Errorf("expected a base64 encoded minisign public key")
+	}
+
+	return raw, nil
+}
+
+/*
+verifyMinisign verifies a minisign signature, including its trusted comment.
+Arguments:
+
+	key<interface{}>: The minisign public key.
+	filePath<string>: The path to the signed file.
+	signature<[]byte>: The contents of the .minisig file.
+
+Returns:
+
+	error: An error if the signature is invalid.
+*/
+func verifyMinisign(key interface{}, filePath string, signature []byte) error {
+	raw := key.([]byte)
+	keyID, publicKey := raw[2:10], ed25519.PublicKey(raw[10:42])
+
+	var lines []string
+	for _, line := range strings.Split(string(signature), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("not a minisign signature")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 74 {
+		return fmt.Errorf("not a minisign signature")
+	}
+
+	if !bytes.Equal(sig[2:10], keyID) {
+		return fmt.Errorf("signed by a different key (%X)", sig[2:10])
+	}
+
+	// "ED" signatures are made over the BLAKE2b-512 hash of the file, legacy "Ed" ones over the file itself.
+	var message []byte
+	switch string(sig[:2]) {
+	case "ED":
+		h, _ := blake2b.New512(nil)
+		if err := copyFile(h, filePath); err != nil {
+			return err
+		}
+		message = h.Sum(nil)
+	case "Ed":
+		if message, err = ioutil.ReadFile(filePath); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm")
+	}
+
+	if !ed25519.Verify(publicKey, message, sig[10:]) {
+		return fmt.Errorf("signature does not match")
+	}
+
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || !ed25519.Verify(publicKey, append(append([]byte{}, sig[10:]...), strings.TrimPrefix(lines[2], "trusted comment: ")...), globalSig) {
+		return fmt.Errorf("trusted comment does not match")
+	}
+
+	return nil
+}
+
+/*
+parseGPGKey parses an armored or binary GPG public key ring.
+Arguments:
+
+	data<[]byte>: The key data.
+
+Returns:
+
+	interface{}: The parsed key ring.
+	error: An error if the key ring is invalid.
+*/
+func parseGPGKey(data []byte) (interface{}, error) {
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+/*
+verifyGPG verifies an armored or binary detached GPG signature.
+Arguments:
+
+	key<interface{}>: The GPG key ring.
+	filePath<string>: The path to the signed file.
+	signature<[]byte>: The contents of the signature file.
+
+Returns:
+
+	error: An error if the signature is invalid.
+*/
+func verifyGPG(key interface{}, filePath string, signature []byte) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if bytes.Contains(signature, []byte("-----BEGIN PGP SIGNATURE")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(key.(openpgp.EntityList), file, bytes.NewReader(signature), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(key.(openpgp.EntityList), file, bytes.NewReader(signature), nil)
+	}
+
+	return err
+}
+
+/*
+parseCosignKey parses a PEM encoded cosign public key.
+Arguments:
+
+	data<[]byte>: The key data.
+
+Returns:
+
+	interface{}: The parsed public key.
+	error: An error if the key is invalid.
+*/
+func parseCosignKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("expected a PEM encoded public key")
+	}
+
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// cosignBundle holds the fields of cosign bundles and Sigstore bundles that carry the signature.
+type cosignBundle struct {
+	Base64Signature  string `json:"base64Signature"`
+	MessageSignature struct {
+		Signature string `json:"signature"`
+	} `json:"messageSignature"`
+}
+
+/*
+verifyCosign verifies a cosign blob signature or the signature inside of a cosign or Sigstore bundle against a public key.
+The transparency log entries of bundles are not checked, so keyless signatures can't be verified.
+Arguments:
+
+	key<interface{}>: The cosign public key.
+	filePath<string>: The path to the signed file.
+	signature<[]byte>: The contents of the signature or bundle file.
+
+Returns:
+
+	error: An error if the signature is invalid.
+*/
+func verifyCosign(key interface{}, filePath string, signature []byte) error {
+	encoded := strings.TrimSpace(string(signature))
+	if strings.HasPrefix(encoded, "{") {
+		bundle := &cosignBundle{}
+		if err := json.Unmarshal(signature, bundle); err != nil {
+			return fmt.Errorf("invalid bundle: %w", err)
+		}
+
+		encoded = bundle.Base64Signature
+		if encoded == "" {
+			encoded = bundle.MessageSignature.Signature
+		}
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sig) == 0 {
+		return fmt.Errorf("not a cosign signature")
+	}
+
+	if publicKey, ok := key.(ed25519.PublicKey); ok {
+		message, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		if !ed25519.Verify(publicKey, message, sig) {
+			return fmt.Errorf("signature does not match")
+		}
+		return nil
+	}
+
+	h := sha256.New()
+	if err := copyFile(h, filePath); err != nil {
+		return err
+	}
+	digest := h.Sum(nil)
+
+	switch publicKey := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(publicKey, digest, sig) {
+			return fmt.Errorf("signature does not match")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, sig); err != nil {
+			return fmt.Errorf("signature does not match")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+
+	return nil
+}
+
+/*
+copyFile writes the contents of the given file to the given writer, such as a hash.
+Arguments:
+
+	w<io.Writer>: The writer.
+	filePath<string>: The path to the file.
+
+Returns:
+
+	error: An error if one occurs.
+*/
+func copyFile(w io.Writer, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+/*
+hasAnySuffix returns whether the string ends with any of the given suffixes.
+Arguments:
+
+	s<string>: The string.
+	suffixes<[]string>: The suffixes.
+
+Returns:
+
+	bool: Whether the string has any of the suffixes.
+*/
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,377 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Release is a single release published by a source, independent of the host it came from.
+type Release struct {
+	TagName     string
+	Name        string
+	Body        string
+	PublishedAt time.Time
+	Assets      []*Asset
+}
+
+// Asset is a single downloadable file attached to a release.
+type Asset struct {
+	Name        string
+	Size        int64
+	DownloadURL string
+}
+
+// Source is a place that runner releases can be fetched from, such as a GitHub repository.
+type Source interface {
+	// String returns a human readable description of the source.
+	String() string
+
+	// ListReleases returns up to limit releases published by the source, newest first.
+	// Paginated sources only fetch as many pages as are needed, a limit of 0 or less fetches every release up to maxReleases.
+	ListReleases(ctx context.Context, limit int) ([]*Release, error)
+
+	// GetRelease returns the release with the given tag.
+	GetRelease(ctx context.Context, tag string) (*Release, error)
+
+	// ListAssets returns the assets attached to the given release.
+	ListAssets(ctx context.Context, release *Release) ([]*Asset, error)
+
+	// OpenAsset opens a stream to the contents of the given asset, alongside its size if known (or -1).
+	OpenAsset(ctx context.Context, asset *Asset) (io.ReadCloser, int64, error)
+}
+
+// maxReleases caps the number of releases fetched from a source, so listing every release of a long-lived source doesn't page forever.
+const maxReleases = 1000
+
+// sourceTypes maps a source type prefix (eg. "github" in "github:owner/repo") to the function that creates it.
+var sourceTypes = map[string]func(location string, options url.Values) (Source, error){
+	"github":  newGitHubSource,
+	"gitlab":  newGitLabSource,
+	"gitea":   newGiteaSource,
+	"forgejo": newGiteaSource,
+	"index":   newIndexSource,
+	"dir":     newDirSource,
+}
+
+// defaultSourceType is the source type used when a source entry has no type prefix.
+const defaultSourceType = "github"
+
+/*
+SplitSourceEntry splits a source entry into its type, location and options.
+Entries are written as "[type:]location[?options]", where options are URL query encoded (eg. "?name=mirror").
+Arguments:
+
+	entry<string>: The source entry to split.
+
+Example:
+
+	kind, location, options, err := SplitSourceEntry("index:https://example.com/index.json?name=mirror")
+	fmt.Println(kind) // index
+	fmt.Println(location) // https://example.com/index.json
+	fmt.Println(options.Get("name")) // mirror
+
+Returns:
+
+	string: The type of the source.
+	string: The location of the source.
+	url.Values: The options of the source.
+	error: Any errors that occur.
+*/
+func SplitSourceEntry(entry string) (string, string, url.Values, error) {
+	kind, location := defaultSourceType, entry
+
+	if i := strings.Index(entry, ":"); i > 0 {
+		if _, ok := sourceTypes[entry[:i]]; ok {
+			kind, location = entry[:i], entry[i+1:]
+		}
+	}
+
+	location, rawOptions, _ := strings.Cut(location, "?")
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid options for source %q: %w", entry, err)
+	}
+
+	return kind, location, options, nil
+}
+
+/*
+ParseSource creates a source from a source entry, which is optionally prefixed by its type.
+Arguments:
+
+	entry<string>: The source entry to parse.
+
+Example:
+
+	source, err := ParseSource("github:GloriousEggroll/proton-ge-custom")
+	fmt.Println(source) // GloriousEggroll/proton-ge-custom
+
+Returns:
+
+	Source: The source described by the entry.
+	error: Any errors that occur.
+*/
+func ParseSource(entry string) (Source, error) {
+	kind, location, options, err := SplitSourceEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	Debug("ParseSource: Parsing " + kind + " source: " + location)
+
+	source, err := sourceTypes[kind](location, options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s source %q: %w", kind, entry, err)
+	}
+
+	if _, err := newAssetFilter(options); err != nil {
+		return nil, fmt.Errorf("invalid %s source %q: %w", kind, entry, err)
+	}
+
+	if _, err := newTrustPolicy(options); err != nil {
+		return nil, fmt.Errorf("invalid %s source %q: %w", kind, entry, err)
+	}
+
+	return source, nil
+}
+
+/*
+GetSourceName returns the name given to a source entry with the "name" option, or an empty string if it has none.
+Arguments:
+
+	entry<string>: The source entry.
+
+Example:
+
+	name := GetSourceName("index:https://example.com/index.json?name=mirror")
+	fmt.Println(name) // mirror
+
+Returns:
+
+	string: The name of the source.
+*/
+func GetSourceName(entry string) string {
+	_, _, options, err := SplitSourceEntry(entry)
+	if err != nil {
+		return ""
+	}
+
+	return options.Get("name")
+}
+
+/*
+SetSourceOption returns the source entry with the given option set, replacing any existing value. An empty value removes the option.
+Arguments:
+
+	entry<string>: The source entry.
+	key<string>: The name of the option.
+	value<string>: The value of the option.
+
+Example:
+
+	entry, err := SetSourceOption("index:https://example.com/index.json", "name", "mirror")
+	fmt.Println(entry) // index:https://example.com/index.json?name=mirror
+
+Returns:
+
+	string: The updated source entry.
+	error: Any errors that occur.
+*/
+func SetSourceOption(entry, key, value string) (string, error) {
+	if value == "" {
+		return SetSourceOptionValues(entry, key, nil)
+	}
+
+	return SetSourceOptionValues(entry, key, []string{value})
+}
+
+/*
+SetSourceOptionValues returns the source entry with the given option set to every one of the given values, replacing any existing values.
+No values removes the option.
+Arguments:
+
+	entry<string>: The source entry.
+	key<string>: The name of the option.
+	values<[]string>: The values of the option.
+
+Example:
+
+	entry, err := SetSourceOptionValues("owner/repo", "exclude", []string{"*-debug.tar.gz", "*.zip"})
+	fmt.Println(entry) // owner/repo?exclude=%2A-debug.tar.gz&exclude=%2A.zip
+
+Returns:
+
+	string: The updated source entry.
+	error: Any errors that occur.
+*/
+func SetSourceOptionValues(entry, key string, values []string) (string, error) {
+	base, rawOptions, _ := strings.Cut(entry, "?")
+	options, err := url.ParseQuery(rawOptions)
+	if err != nil {
+		return "", fmt.Errorf("invalid options for source %q: %w", entry, err)
+	}
+
+	if len(values) == 0 {
+		options.Del(key)
+	} else {
+		options[key] = values
+	}
+
+	if len(options) == 0 {
+		return base, nil
+	}
+
+	return base + "?" + options.Encode(), nil
+}
+
+/*
+GetSource returns the source at the specified source index.
+Arguments:
+
+	entryIndex<int>: The index of the source to get.
+
+Example:
+
+	source, err := GetSource(0)
+
+Returns:
+
+	Source: The source at the specified index.
+	error: Any errors that occur.
+*/
+func GetSource(entryIndex int) (Source, error) {
+	sources := viper.GetStringSlice("app.sources")
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no sources have been configured, please add a source with `proto config sources add <owner/repo>`")
+	}
+
+	if entryIndex < 0 || entryIndex >= len(sources) {
+		return nil, fmt.Errorf("there is no source at index %d", entryIndex+1)
+	}
+
+	return ParseSource(sources[entryIndex])
+}
+
+/*
+releaseLimit returns the number of releases to fetch for the given limit, applying maxReleases.
+Arguments:
+
+	limit<int>: The requested limit, 0 or less for every release.
+
+Returns:
+
+	int: The number of releases to fetch.
+*/
+func releaseLimit(limit int) int {
+	if limit <= 0 || limit > maxReleases {
+		return maxReleases
+	}
+
+	return limit
+}
+
+/*
+truncateReleases cuts the given releases down to the given limit, for sources that always return every release at once.
+Arguments:
+
+	releases<[]*Release>: The releases to truncate.
+	limit<int>: The requested limit, 0 or less for every release.
+
+Returns:
+
+	[]*Release: The truncated releases.
+*/
+func truncateReleases(releases []*Release, limit int) []*Release {
+	if limit = releaseLimit(limit); len(releases) > limit {
+		return releases[:limit]
+	}
+
+	return releases
+}
+
+/*
+newAPIHTTPClient creates the HTTP client used to talk to release APIs, which caches responses on disk and authenticates every request with the given token (if any).
+Arguments:
+
+	token<string>: The token to authenticate with, or an empty string.
+
+Returns:
+
+	*http.Client: The HTTP client.
+*/
+func newAPIHTTPClient(token string) *http.Client {
+	var transport http.RoundTripper = &cacheTransport{base: http.DefaultTransport}
+	if token != "" {
+		transport = &tokenTransport{token: token, base: transport}
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+/*
+openHTTPAsset opens a stream to the file at the given URL, following redirects if needed.
+The stream is cut off if the server stops sending data for longer than the read timeout.
+Arguments:
+
+	ctx<context.Context>: The context for the request.
+	url<string>: The URL to fetch.
+
+Returns:
+
+	io.ReadCloser: The body of the response.
+	int64: The length of the body, or -1 if unknown.
+	error: Any errors that occur.
+*/
+func openHTTPAsset(ctx context.Context, url string) (io.ReadCloser, int64, error) {
+	_, readTimeout := GetDownloadTimeouts()
+	ctx, cancel := context.WithCancel(ctx)
+
+	body, size, err := openHTTP(ctx, newDownloadHTTPClient(), url)
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+
+	return newIdleTimeoutReader(body, readTimeout, cancel), size, nil
+}
+
+/*
+openHTTP opens a stream to the file at the given URL using the given client, following redirects if needed.
+Arguments:
+
+	ctx<context.Context>: The context for the request.
+	client<*http.Client>: The client to make the request with.
+	url<string>: The URL to fetch.
+
+Returns:
+
+	io.ReadCloser: The body of the response.
+	int64: The length of the body, or -1 if unknown.
+	error: Any errors that occur.
+*/
+func openHTTP(ctx context.Context, client *http.Client, url string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("unexpected response from %s: %s", url, resp.Status)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
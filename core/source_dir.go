@@ -0,0 +1,265 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirSource is a source backed by a local (or network mounted) directory of runner archives.
+// Every archive in the directory is a release, tagged with the file name minus its extension.
+type dirSource struct {
+	path string
+}
+
+/*
+newDirSource creates a directory source from the path of the directory.
+Arguments:
+
+	location<string>: The path to the directory, eg. "/mnt/runners" or "~/runners".
+	options<url.Values>: The options of the source entry.
+
+Returns:
+
+	Source: The directory source.
+	error: Any errors that occur.
+*/
+func newDirSource(location string, options url.Values) (Source, error) {
+	if location == "" {
+		return nil, fmt.Errorf("expected the path to a directory")
+	}
+
+	path := UsePath(location, false)
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("expected an absolute path to a directory")
+	}
+
+	return &dirSource{path: path}, nil
+}
+
+func (s *dirSource) String() string {
+	return "dir:" + s.path
+}
+
+func (s *dirSource) ListReleases(ctx context.Context, limit int) ([]*Release, error) {
+	files, err := s.readDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Release
+	for _, file := range files {
+		if _, ok := TrimArchiveExtension(file.Name()); !ok {
+			continue
+		}
+
+		result = append(result, s.newRelease(file, files))
+	}
+
+	// Treat the most recently modified archive as the latest release.
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].PublishedAt.After(result[j].PublishedAt)
+	})
+
+	return truncateReleases(result, limit), nil
+}
+
+func (s *dirSource) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	if strings.ContainsAny(tag, `/\`) {
+		return nil, fmt.Errorf("invalid release tag %s", tag)
+	}
+
+	files, err := s.readDir()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, extension := range GetArchiveExtensions() {
+		if file := findFile(files, tag+extension); file != nil {
+			return s.newRelease(file, files), nil
+		}
+	}
+
+	return nil, fmt.Errorf("release %s was not found in %s", tag, s.path)
+}
+
+func (s *dirSource) ListAssets(ctx context.Context, release *Release) ([]*Asset, error) {
+	// The assets are found when the release is read from the directory, so there is nothing more to fetch.
+	return release.Assets, nil
+}
+
+func (s *dirSource) OpenAsset(ctx context.Context, asset *Asset) (io.ReadCloser, int64, error) {
+	file, err := os.Open(asset.DownloadURL)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return file, asset.Size, nil
+}
+
+func (s *dirSource) assetPath(asset *Asset) (string, bool) {
+	return asset.DownloadURL, true
+}
+
+/*
+readDir lists the files in the source directory, following symlinks. The directory is only read once for each lookup,
+and every release is matched against the same listing.
+Returns:
+
+	[]os.FileInfo: The files in the directory, sorted by name.
+	error: Any errors that occur.
+*/
+func (s *dirSource) readDir() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []os.FileInfo
+	for _, entry := range entries {
+		info, err := os.Stat(filepath.Join(s.path, entry.Name()))
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		files = append(files, info)
+	}
+
+	return files, nil
+}
+
+/*
+newRelease creates the release for an archive in the source directory, along with its checksum file and signatures.
+Arguments:
+
+	archive<os.FileInfo>: The archive.
+	files<[]os.FileInfo>: The files in the source directory.
+
+Returns:
+
+	*Release: The release.
+*/
+func (s *dirSource) newRelease(archive os.FileInfo, files []os.FileInfo) *Release {
+	tag, _ := TrimArchiveExtension(archive.Name())
+
+	release := &Release{
+		TagName:     tag,
+		Name:        tag,
+		PublishedAt: archive.ModTime(),
+		Assets:      []*Asset{s.fileAsset(archive)},
+	}
+
+	// Checksums can either be named after the archive or after the tag with the extension of any supported algorithm,
+	// or be an aggregate file covering every archive in the directory.
+	if sumInfo := findChecksum(files, archive.Name(), tag); sumInfo != nil {
+		release.Assets = append(release.Assets, s.fileAsset(sumInfo))
+	}
+
+	// Detached signatures of the archive and checksum file sit next to them.
+	for _, asset := range release.Assets {
+		release.Assets = append(release.Assets, s.findSignatures(files, asset.Name)...)
+	}
+
+	return release
+}
+
+/*
+findChecksum looks for a checksum file next to an archive, named after either the archive or its tag, falling back to
+an aggregate checksum file (eg. "SHA256SUMS") in the directory.
+Arguments:
+
+	files<[]os.FileInfo>: The files in the source directory.
+	archiveName<string>: The file name of the archive.
+	tag<string>: The tag of the release.
+
+Returns:
+
+	os.FileInfo: The checksum file, or nil if there is none.
+*/
+func findChecksum(files []os.FileInfo, archiveName, tag string) os.FileInfo {
+	for _, base := range []string{archiveName, tag} {
+		for _, algorithm := range checksumAlgorithms {
+			for _, extension := range algorithm.extensions {
+				if file := findFile(files, base+extension); file != nil {
+					return file
+				}
+			}
+		}
+	}
+
+	for _, file := range files {
+		if IsAggregateChecksumFile(file.Name()) {
+			return file
+		}
+	}
+
+	return nil
+}
+
+/*
+findSignatures looks for detached signatures of a file in the source directory, named after the file with a signature extension.
+Arguments:
+
+	files<[]os.FileInfo>: The files in the source directory.
+	name<string>: The name of the signed file.
+
+Returns:
+
+	[]*Asset: The signatures of the file.
+*/
+func (s *dirSource) findSignatures(files []os.FileInfo, name string) []*Asset {
+	var result []*Asset
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), name+".") && IsSignatureFile(file.Name()) {
+			result = append(result, s.fileAsset(file))
+		}
+	}
+
+	return result
+}
+
+/*
+findFile returns the file with the given name from a directory listing.
+Arguments:
+
+	files<[]os.FileInfo>: The files in the directory, sorted by name.
+	name<string>: The name of the file.
+
+Returns:
+
+	os.FileInfo: The file, or nil if it isn't in the listing.
+*/
+func findFile(files []os.FileInfo, name string) os.FileInfo {
+	i := sort.Search(len(files), func(i int) bool {
+		return files[i].Name() >= name
+	})
+
+	if i < len(files) && files[i].Name() == name {
+		return files[i]
+	}
+
+	return nil
+}
+
+/*
+fileAsset creates an asset for a file inside of the source directory.
+Arguments:
+
+	info<os.FileInfo>: The file to create the asset for.
+
+Returns:
+
+	*Asset: The asset for the file.
+*/
+func (s *dirSource) fileAsset(info os.FileInfo) *Asset {
+	return &Asset{
+		Name:        info.Name(),
+		Size:        info.Size(),
+		DownloadURL: filepath.Join(s.path, info.Name()),
+	}
+}
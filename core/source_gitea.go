@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	gitea "code.gitea.io/sdk/gitea"
+)
+
+// defaultGiteaURL is the Gitea compatible instance used when a Gitea source does not specify one.
+const defaultGiteaURL = "https://codeberg.org"
+
+// giteaSource is a source backed by the releases of a repository on a Gitea compatible forge (Gitea, Forgejo, Codeberg).
+type giteaSource struct {
+	baseURL string
+	owner   string
+	repo    string
+	client  *gitea.Client
+}
+
+/*
+newGiteaSource creates a Gitea source from an "owner/repo" location, optionally prefixed with the URL of a self-hosted instance.
+Arguments:
+
+	location<string>: The repository, eg. "owner/repo" or "https://gitea.example.com/owner/repo".
+	options<url.Values>: The options of the source entry.
+
+Returns:
+
+	Source: The Gitea source.
+	error: Any errors that occur.
+*/
+func newGiteaSource(location string, options url.Values) (Source, error) {
+	baseURL, project, err := splitForgeLocation(location, defaultGiteaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	split := strings.Split(project, "/")
+	if len(split) != 2 {
+		return nil, fmt.Errorf("expected a repository in the format owner/repo")
+	}
+
+	// Skip the server version check, it costs a request and Forgejo reports versions that the SDK cannot compare.
+	client, err := gitea.NewClient(baseURL, gitea.SetGiteaVersion(""), gitea.SetHTTPClient(newAPIHTTPClient("")))
+	if err != nil {
+		return nil, err
+	}
+
+	return &giteaSource{
+		baseURL: baseURL,
+		owner:   split[0],
+		repo:    split[1],
+		client:  client,
+	}, nil
+}
+
+func (s *giteaSource) String() string {
+	return "gitea:" + s.baseURL + "/" + s.owner + "/" + s.repo
+}
+
+func (s *giteaSource) ListReleases(ctx context.Context, limit int) ([]*Release, error) {
+	limit = releaseLimit(limit)
+	s.client.SetContext(ctx)
+
+	// Servers can cap pages below the requested size, so a short page doesn't mean it is the last one.
+	// Paging stops at an empty page, or once every release has been seen if the server reports how many there are.
+	opts := gitea.ListReleasesOptions{ListOptions: gitea.ListOptions{Page: 1, PageSize: 50}}
+
+	var result []*Release
+	seen := 0
+	for len(result) < limit {
+		releases, resp, err := s.client.ListReleases(s.owner, s.repo, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(releases) == 0 {
+			break
+		}
+
+		for _, release := range releases {
+			if release.IsDraft {
+				continue
+			}
+
+			result = append(result, convertGiteaRelease(release))
+		}
+
+		seen += len(releases)
+		if total, err := strconv.Atoi(resp.Header.Get("X-Total-Count")); err == nil && seen >= total {
+			break
+		}
+
+		opts.Page++
+		Debug(fmt.Sprintf("ListReleases: Fetching page %d of releases for %s", opts.Page, s))
+	}
+
+	return truncateReleases(result, limit), nil
+}
+
+func (s *giteaSource) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	s.client.SetContext(ctx)
+	release, _, err := s.client.GetReleaseByTag(s.owner, s.repo, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertGiteaRelease(release), nil
+}
+
+func (s *giteaSource) ListAssets(ctx context.Context, release *Release) ([]*Asset, error) {
+	// Gitea includes the attachments in the release payload, so there is nothing more to fetch.
+	return release.Assets, nil
+}
+
+func (s *giteaSource) OpenAsset(ctx context.Context, asset *Asset) (io.ReadCloser, int64, error) {
+	return openHTTPAsset(ctx, asset.DownloadURL)
+}
+
+func (s *giteaSource) assetURL(asset *Asset) (string, bool) {
+	return asset.DownloadURL, true
+}
+
+/*
+convertGiteaRelease converts a Gitea SDK release into a source independent release.
+Arguments:
+
+	release<*gitea.Release>: The release to convert.
+
+Returns:
+
+	*Release: The converted release.
+*/
+func convertGiteaRelease(release *gitea.Release) *Release {
+	result := &Release{
+		TagName:     release.TagName,
+		Name:        release.Title,
+		Body:        release.Note,
+		PublishedAt: release.PublishedAt,
+	}
+
+	for _, attachment := range release.Attachments {
+		result.Assets = append(result.Assets, &Asset{
+			Name:        attachment.Name,
+			Size:        attachment.Size,
+			DownloadURL: attachment.DownloadURL,
+		})
+	}
+
+	return result
+}
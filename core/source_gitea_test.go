@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestGiteaSourceListReleases(t *testing.T) {
+	tests := []struct {
+		name       string
+		totalCount bool
+		requests   int
+	}{
+		{name: "with X-Total-Count", totalCount: true, requests: 3},
+		{name: "without X-Total-Count", requests: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Keep the responses out of the user's metadata cache.
+			t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+			// The server has 70 releases, but caps pages at 30 no matter what is asked for.
+			const releaseCount, maxPageSize = 70, 30
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/repos/owner/repo/releases" {
+					http.NotFound(w, r)
+					return
+				}
+
+				requests++
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+				var releases []map[string]string
+				for i := (page - 1) * maxPageSize; i < page*maxPageSize && i < releaseCount; i++ {
+					releases = append(releases, map[string]string{"tag_name": fmt.Sprintf("GE-Test%d", i)})
+				}
+
+				if test.totalCount {
+					w.Header().Set("X-Total-Count", strconv.Itoa(releaseCount))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(releases)
+			}))
+			t.Cleanup(server.Close)
+
+			source, err := newGiteaSource(server.URL+"/owner/repo", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			releases, err := source.ListReleases(context.Background(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(releases) != releaseCount {
+				t.Errorf("ListReleases() returned %d releases, want %d", len(releases), releaseCount)
+			}
+
+			if requests != test.requests {
+				t.Errorf("made %d requests, want %d", requests, test.requests)
+			}
+		})
+	}
+}
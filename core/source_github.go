@@ -0,0 +1,260 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	github "github.com/google/go-github/v44/github"
+)
+
+// gitHubSource is a source backed by the releases of a GitHub (or GitHub Enterprise Server) repository.
+type gitHubSource struct {
+	host   string
+	owner  string
+	repo   string
+	client *github.Client
+}
+
+// defaultGitHubHost is the host of public GitHub, which does not need any enterprise endpoints.
+const defaultGitHubHost = "github.com"
+
+// gitHubEnterpriseTokenEnvVars are the environment variables checked for a GitHub Enterprise Server token, unless the source names its own.
+var gitHubEnterpriseTokenEnvVars = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+
+/*
+newGitHubSource creates a GitHub source from an "owner/repo" location, which can be qualified with the host of a GitHub Enterprise Server.
+Enterprise sources use the "https://<host>/api/v3/" and "https://<host>/api/uploads/" endpoints unless the "api" and "upload" options are set,
+and authenticate with the token in the environment variable named by the "token-env" option (or GH_ENTERPRISE_TOKEN / GITHUB_ENTERPRISE_TOKEN).
+Arguments:
+
+	location<string>: The repository, eg. "owner/repo", "ghe.example.com/owner/repo" or "https://ghe.example.com/owner/repo".
+	options<url.Values>: The options of the source entry.
+
+Returns:
+
+	Source: The GitHub source.
+	error: Any errors that occur.
+*/
+func newGitHubSource(location string, options url.Values) (Source, error) {
+	host := defaultGitHubHost
+	scheme := "https"
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		parsed, err := url.Parse(location)
+		if err != nil {
+			return nil, err
+		}
+
+		scheme, host, location = parsed.Scheme, parsed.Host, parsed.Path
+	}
+
+	split := strings.Split(strings.Trim(location, "/"), "/")
+	if len(split) == 3 && host == defaultGitHubHost {
+		host, split = split[0], split[1:]
+	}
+
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return nil, fmt.Errorf("expected a repository in the format owner/repo or host/owner/repo")
+	}
+
+	source := &gitHubSource{
+		host:  host,
+		owner: split[0],
+		repo:  split[1],
+	}
+
+	// Public GitHub uses the default endpoints and the user's GitHub token.
+	if host == defaultGitHubHost {
+		source.client = github.NewClient(newAPIHTTPClient(GetGitHubToken()))
+		return source, nil
+	}
+
+	apiURL := options.Get("api")
+	if apiURL == "" {
+		apiURL = scheme + "://" + host + "/api/v3/"
+	}
+
+	uploadURL := options.Get("upload")
+	if uploadURL == "" {
+		uploadURL = scheme + "://" + host + "/api/uploads/"
+	}
+
+	Debug("newGitHubSource: Using enterprise endpoints " + apiURL + " and " + uploadURL)
+
+	client, err := github.NewEnterpriseClient(apiURL, uploadURL, newAPIHTTPClient(getGitHubEnterpriseToken(options.Get("token-env"))))
+	if err != nil {
+		return nil, err
+	}
+
+	source.client = client
+	return source, nil
+}
+
+func (s *gitHubSource) String() string {
+	if s.host != defaultGitHubHost {
+		return s.host + "/" + s.owner + "/" + s.repo
+	}
+
+	return s.owner + "/" + s.repo
+}
+
+func (s *gitHubSource) ListReleases(ctx context.Context, limit int) ([]*Release, error) {
+	// Always ask for full pages, so that the same cached pages are reused no matter the limit.
+	limit = releaseLimit(limit)
+	opts := &github.ListOptions{PerPage: 100}
+
+	var result []*Release
+	for len(result) < limit {
+		releases, resp, err := s.client.Repositories.ListReleases(ctx, s.owner, s.repo, opts)
+		logGitHubRate(resp)
+		if err != nil {
+			return nil, s.gitHubError(err)
+		}
+
+		for _, release := range releases {
+			result = append(result, convertGitHubRelease(release))
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		Debug(fmt.Sprintf("ListReleases: Fetching page %d of releases for %s", resp.NextPage, s))
+		opts.Page = resp.NextPage
+	}
+
+	return truncateReleases(result, limit), nil
+}
+
+func (s *gitHubSource) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	release, resp, err := s.client.Repositories.GetReleaseByTag(ctx, s.owner, s.repo, tag)
+	logGitHubRate(resp)
+	if err != nil {
+		return nil, s.gitHubError(err)
+	}
+
+	return convertGitHubRelease(release), nil
+}
+
+func (s *gitHubSource) ListAssets(ctx context.Context, release *Release) ([]*Asset, error) {
+	// GitHub includes the assets in the release payload, so there is nothing more to fetch.
+	return release.Assets, nil
+}
+
+func (s *gitHubSource) OpenAsset(ctx context.Context, asset *Asset) (io.ReadCloser, int64, error) {
+	return openHTTPAsset(ctx, asset.DownloadURL)
+}
+
+func (s *gitHubSource) assetURL(asset *Asset) (string, bool) {
+	return asset.DownloadURL, true
+}
+
+/*
+convertGitHubRelease converts a go-github release into a source independent release.
+Arguments:
+
+	release<*github.RepositoryRelease>: The release to convert.
+
+Returns:
+
+	*Release: The converted release.
+*/
+func convertGitHubRelease(release *github.RepositoryRelease) *Release {
+	result := &Release{
+		TagName:     release.GetTagName(),
+		Name:        release.GetName(),
+		Body:        release.GetBody(),
+		PublishedAt: release.GetPublishedAt().Time,
+	}
+
+	for _, asset := range release.Assets {
+		result.Assets = append(result.Assets, &Asset{
+			Name:        asset.GetName(),
+			Size:        int64(asset.GetSize()),
+			DownloadURL: asset.GetBrowserDownloadURL(),
+		})
+	}
+
+	return result
+}
+
+/*
+logGitHubRate prints the remaining GitHub API rate limit of the response in verbose mode.
+Arguments:
+
+	resp<*github.Response>: The response from the GitHub API, may be nil or have no rate limit if it was cached.
+*/
+func logGitHubRate(resp *github.Response) {
+	// Responses served from the cache (or a 304) don't carry the rate limit headers.
+	if resp == nil || resp.Rate.Limit == 0 {
+		return
+	}
+
+	Debug(fmt.Sprintf("GitHub: %d/%d API requests remaining, resets at %s", resp.Rate.Remaining, resp.Rate.Limit, resp.Rate.Reset.Local().Format("2006-01-02 15:04:05")))
+}
+
+/*
+gitHubError turns GitHub API rate limit errors into an error explaining when the limit resets and how to raise it, other errors are returned as-is.
+Arguments:
+
+	err<error>: The error from the GitHub API.
+
+Returns:
+
+	error: The error to show to the user.
+*/
+func (s *gitHubSource) gitHubError(err error) error {
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		hint := ""
+		if s.host == defaultGitHubHost && GetGitHubToken() == "" {
+			hint = ", set PROTO_GITHUB_TOKEN or GITHUB_TOKEN (or run 'proto config github-token <token>') to raise the limit"
+		}
+
+		return fmt.Errorf("the GitHub API rate limit of %d requests per hour has been used up, it resets at %s%s", rateErr.Rate.Limit, rateErr.Rate.Reset.Local().Format("2006-01-02 15:04:05"), hint)
+	}
+
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter != nil {
+			return fmt.Errorf("the GitHub API secondary rate limit was hit, try again in %s", abuseErr.RetryAfter.Round(time.Second))
+		}
+
+		return fmt.Errorf("the GitHub API secondary rate limit was hit, try again later")
+	}
+
+	return err
+}
+
+/*
+getGitHubEnterpriseToken returns the token used to authenticate with a GitHub Enterprise Server, or an empty string if none is set.
+The token for public GitHub is never sent to an enterprise server.
+Arguments:
+
+	envVar<string>: The environment variable the source reads its token from, or an empty string to use the defaults.
+
+Returns:
+
+	string: The GitHub Enterprise Server token.
+*/
+func getGitHubEnterpriseToken(envVar string) string {
+	envVars := gitHubEnterpriseTokenEnvVars
+	if envVar != "" {
+		envVars = []string{envVar}
+	}
+
+	for _, envVar := range envVars {
+		if token := strings.TrimSpace(os.Getenv(envVar)); token != "" {
+			Debug("getGitHubEnterpriseToken: Using token from " + envVar)
+			return token
+		}
+	}
+
+	return ""
+}
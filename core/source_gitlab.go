@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// defaultGitLabURL is the GitLab instance used when a GitLab source does not specify one.
+const defaultGitLabURL = "https://gitlab.com"
+
+// gitLabSource is a source backed by the releases of a GitLab project.
+type gitLabSource struct {
+	baseURL string
+	project string
+	client  *gitlab.Client
+}
+
+/*
+newGitLabSource creates a GitLab source from a "group/project" location, optionally prefixed with the URL of a self-hosted instance.
+Arguments:
+
+	location<string>: The project, eg. "group/project" or "https://gitlab.example.com/group/project".
+	options<url.Values>: The options of the source entry.
+
+Returns:
+
+	Source: The GitLab source.
+	error: Any errors that occur.
+*/
+func newGitLabSource(location string, options url.Values) (Source, error) {
+	baseURL, project, err := splitForgeLocation(location, defaultGitLabURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := gitlab.NewClient("", gitlab.WithBaseURL(baseURL+"/api/v4"), gitlab.WithHTTPClient(newAPIHTTPClient("")))
+	if err != nil {
+		return nil, err
+	}
+
+	return &gitLabSource{
+		baseURL: baseURL,
+		project: project,
+		client:  client,
+	}, nil
+}
+
+func (s *gitLabSource) String() string {
+	if s.baseURL == defaultGitLabURL {
+		return "gitlab:" + s.project
+	}
+
+	return "gitlab:" + s.baseURL + "/" + s.project
+}
+
+func (s *gitLabSource) ListReleases(ctx context.Context, limit int) ([]*Release, error) {
+	// Always ask for full pages, so that the same cached pages are reused no matter the limit.
+	limit = releaseLimit(limit)
+	opts := &gitlab.ListReleasesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+
+	var result []*Release
+	for len(result) < limit {
+		releases, resp, err := s.client.Releases.ListReleases(s.project, opts, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, release := range releases {
+			result = append(result, convertGitLabRelease(release))
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		Debug(fmt.Sprintf("ListReleases: Fetching page %d of releases for %s", resp.NextPage, s))
+		opts.Page = resp.NextPage
+	}
+
+	return truncateReleases(result, limit), nil
+}
+
+func (s *gitLabSource) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	release, _, err := s.client.Releases.GetRelease(s.project, tag, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return convertGitLabRelease(release), nil
+}
+
+func (s *gitLabSource) ListAssets(ctx context.Context, release *Release) ([]*Asset, error) {
+	// GitLab includes the asset links in the release payload, so there is nothing more to fetch.
+	return release.Assets, nil
+}
+
+func (s *gitLabSource) OpenAsset(ctx context.Context, asset *Asset) (io.ReadCloser, int64, error) {
+	return openHTTPAsset(ctx, asset.DownloadURL)
+}
+
+func (s *gitLabSource) assetURL(asset *Asset) (string, bool) {
+	return asset.DownloadURL, true
+}
+
+/*
+convertGitLabRelease converts a go-gitlab release into a source independent release.
+Release links (including links to generic packages) become assets, the automatically generated source archives are ignored.
+Arguments:
+
+	release<*gitlab.Release>: The release to convert.
+
+Returns:
+
+	*Release: The converted release.
+*/
+func convertGitLabRelease(release *gitlab.Release) *Release {
+	result := &Release{
+		TagName: release.TagName,
+		Name:    release.Name,
+		Body:    release.Description,
+	}
+
+	if release.ReleasedAt != nil {
+		result.PublishedAt = *release.ReleasedAt
+	} else if release.CreatedAt != nil {
+		result.PublishedAt = *release.CreatedAt
+	}
+
+	for _, link := range release.Assets.Links {
+		// Prefer the permanent direct asset URL, which redirects to wherever the link points.
+		downloadURL := link.DirectAssetURL
+		if downloadURL == "" {
+			downloadURL = link.URL
+		}
+
+		// Links are free-form labels, so fall back to the file name from the URL if the label has no extension.
+		name := link.Name
+		if !strings.Contains(name, ".") {
+			if parsed, err := url.Parse(link.URL); err == nil {
+				name = path.Base(parsed.Path)
+			}
+		}
+
+		// GitLab does not report the size of linked assets.
+		result.Assets = append(result.Assets, &Asset{
+			Name:        name,
+			Size:        0,
+			DownloadURL: downloadURL,
+		})
+	}
+
+	return result
+}
+
+/*
+splitForgeLocation splits a forge project location into the base URL of the instance and the project path.
+Arguments:
+
+	location<string>: The location, eg. "owner/repo" or "https://forge.example.com/owner/repo".
+	defaultURL<string>: The base URL to use if the location does not include one.
+
+Example:
+
+	baseURL, project, err := splitForgeLocation("https://gitlab.example.com/group/project", defaultGitLabURL)
+	fmt.Println(baseURL) // https://gitlab.example.com
+	fmt.Println(project) // group/project
+
+Returns:
+
+	string: The base URL of the instance, without a trailing slash.
+	string: The path of the project.
+	error: Any errors that occur.
+*/
+func splitForgeLocation(location, defaultURL string) (string, string, error) {
+	baseURL := defaultURL
+	project := location
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		parsed, err := url.Parse(location)
+		if err != nil {
+			return "", "", err
+		}
+
+		baseURL = parsed.Scheme + "://" + parsed.Host
+		project = parsed.Path
+	}
+
+	project = strings.TrimSuffix(strings.Trim(project, "/"), ".git")
+	if strings.Count(project, "/") < 1 || strings.Contains(project, "//") {
+		return "", "", fmt.Errorf("expected a project in the format owner/repo")
+	}
+
+	return baseURL, project, nil
+}
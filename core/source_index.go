@@ -0,0 +1,329 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// indexSource is a source backed by a static JSON or YAML release index, served over HTTP(S) or from a file:// URL.
+type indexSource struct {
+	indexURL *url.URL
+}
+
+// indexFile is the layout of a release index file.
+type indexFile struct {
+	Releases []indexRelease `json:"releases" yaml:"releases"`
+}
+
+// indexRelease is a single release inside of a release index file.
+type indexRelease struct {
+	Tag    string       `json:"tag" yaml:"tag"`
+	Name   string       `json:"name" yaml:"name"`
+	Date   string       `json:"date" yaml:"date"`
+	Body   string       `json:"body" yaml:"body"`
+	Assets []indexAsset `json:"assets" yaml:"assets"`
+}
+
+// indexAsset is a single asset of a release inside of a release index file.
+// The checksum is a hex encoded digest prefixed with its algorithm (eg. "sha256:ab12..."), "sha512" is kept for older indexes.
+type indexAsset struct {
+	Name     string `json:"name" yaml:"name"`
+	URL      string `json:"url" yaml:"url"`
+	Size     int64  `json:"size" yaml:"size"`
+	Checksum string `json:"checksum" yaml:"checksum"`
+	SHA512   string `json:"sha512" yaml:"sha512"`
+}
+
+// checksumAssetScheme marks assets whose contents are generated from a checksum listed in a release index.
+const checksumAssetScheme = "checksum:"
+
+/*
+newIndexSource creates a release index source from the URL of the index file.
+Arguments:
+
+	location<string>: The URL of the index, eg. "https://mirror.example.com/proton/index.json" or "file:///srv/mirror/index.yaml".
+	options<url.Values>: The options of the source entry.
+
+Returns:
+
+	Source: The index source.
+	error: Any errors that occur.
+*/
+func newIndexSource(location string, options url.Values) (Source, error) {
+	indexURL, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	switch indexURL.Scheme {
+	case "http", "https", "file":
+	default:
+		return nil, fmt.Errorf("expected an http://, https:// or file:// URL to the index")
+	}
+
+	return &indexSource{indexURL: indexURL}, nil
+}
+
+func (s *indexSource) String() string {
+	return "index:" + s.indexURL.String()
+}
+
+func (s *indexSource) ListReleases(ctx context.Context, limit int) ([]*Release, error) {
+	index, err := s.fetchIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Release, 0, len(index.Releases))
+	for _, release := range index.Releases {
+		converted, err := s.convertRelease(release)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, converted)
+	}
+
+	// Indexes are written by hand or by scripts, so don't trust them to be in order.
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].PublishedAt.After(result[j].PublishedAt)
+	})
+
+	return truncateReleases(result, limit), nil
+}
+
+func (s *indexSource) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	index, err := s.fetchIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, release := range index.Releases {
+		if release.Tag == tag {
+			return s.convertRelease(release)
+		}
+	}
+
+	return nil, fmt.Errorf("release %s was not found in the index", tag)
+}
+
+func (s *indexSource) ListAssets(ctx context.Context, release *Release) ([]*Asset, error) {
+	// The index lists the assets alongside the release, so there is nothing more to fetch.
+	return release.Assets, nil
+}
+
+func (s *indexSource) OpenAsset(ctx context.Context, asset *Asset) (io.ReadCloser, int64, error) {
+	// Checksum assets are generated from the index rather than downloaded.
+	if strings.HasPrefix(asset.DownloadURL, checksumAssetScheme) {
+		contents := strings.TrimPrefix(asset.DownloadURL, checksumAssetScheme)
+		return io.NopCloser(strings.NewReader(contents)), int64(len(contents)), nil
+	}
+
+	return openURL(ctx, newDownloadHTTPClient(), asset.DownloadURL)
+}
+
+func (s *indexSource) assetURL(asset *Asset) (string, bool) {
+	// Assets mirrored on disk (file://) and generated checksums are opened directly instead.
+	return asset.DownloadURL, strings.HasPrefix(asset.DownloadURL, "http://") || strings.HasPrefix(asset.DownloadURL, "https://")
+}
+
+/*
+fetchIndex downloads and decodes the release index, JSON indexes are detected by their leading brace and anything else is treated as YAML.
+Arguments:
+
+	ctx<context.Context>: The context for the request.
+
+Returns:
+
+	*indexFile: The decoded index.
+	error: Any errors that occur.
+*/
+func (s *indexSource) fetchIndex(ctx context.Context) (*indexFile, error) {
+	Debug("fetchIndex: Fetching release index from: " + s.indexURL.String())
+
+	body, _, err := openURL(ctx, newAPIHTTPClient(""), s.indexURL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &indexFile{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, index)
+	} else {
+		err = yaml.Unmarshal(data, index)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the release index at %s: %w", s.indexURL, err)
+	}
+
+	return index, nil
+}
+
+/*
+convertRelease converts a release from the index into a source independent release.
+Asset URLs are resolved relative to the index, and any listed checksums are exposed as checksum file assets.
+Arguments:
+
+	release<indexRelease>: The release to convert.
+
+Returns:
+
+	*Release: The converted release.
+	error: Any errors that occur.
+*/
+func (s *indexSource) convertRelease(release indexRelease) (*Release, error) {
+	result := &Release{
+		TagName: release.Tag,
+		Name:    release.Name,
+		Body:    release.Body,
+	}
+
+	if release.Date != "" {
+		published, err := parseIndexDate(release.Date)
+		if err != nil {
+			return nil, fmt.Errorf("release %s has an invalid date: %w", release.Tag, err)
+		}
+
+		result.PublishedAt = published
+	}
+
+	for _, asset := range release.Assets {
+		assetURL, err := s.indexURL.Parse(asset.URL)
+		if err != nil {
+			return nil, fmt.Errorf("asset %s of release %s has an invalid URL: %w", asset.Name, release.Tag, err)
+		}
+
+		result.Assets = append(result.Assets, &Asset{
+			Name:        asset.Name,
+			Size:        asset.Size,
+			DownloadURL: assetURL.String(),
+		})
+
+		sum, err := indexChecksumAsset(asset)
+		if err != nil {
+			return nil, fmt.Errorf("asset %s of release %s has an invalid checksum: %w", asset.Name, release.Tag, err)
+		}
+
+		if sum != nil {
+			result.Assets = append(result.Assets, sum)
+		}
+	}
+
+	return result, nil
+}
+
+/*
+indexChecksumAsset creates a checksum file asset from the checksum listed for an asset in the index, named after the asset
+with the extension of the checksum's algorithm (eg. "GE-Proton8-25.tar.gz.sha256sum").
+Arguments:
+
+	asset<indexAsset>: The asset from the index.
+
+Returns:
+
+	*Asset: The checksum file asset, or nil if the index doesn't list a checksum for the asset.
+	error: An error if the checksum doesn't use a supported algorithm.
+*/
+func indexChecksumAsset(asset indexAsset) (*Asset, error) {
+	checksum := asset.Checksum
+	if checksum == "" && asset.SHA512 != "" {
+		checksum = "sha512:" + asset.SHA512
+	}
+
+	if checksum == "" {
+		return nil, nil
+	}
+
+	// Digests without a prefix are taken to be sha512 or sha256 rather than the BLAKE algorithms of the same length.
+	algorithms, digest := checksumAlgorithmsFor(checksum)
+	if len(algorithms) == 0 {
+		return nil, fmt.Errorf("%s is not a digest of a supported algorithm", checksum)
+	}
+
+	if len(digest) != algorithms[0].hexLength {
+		return nil, fmt.Errorf("%s is not a valid %s digest", checksum, algorithms[0].name)
+	}
+
+	contents := digest + "  " + asset.Name + "\n"
+	return &Asset{
+		Name:        asset.Name + algorithms[0].extensions[0],
+		Size:        int64(len(contents)),
+		DownloadURL: checksumAssetScheme + contents,
+	}, nil
+}
+
+/*
+parseIndexDate parses a release date from an index, which can either be a full RFC 3339 timestamp or a plain date.
+Arguments:
+
+	date<string>: The date to parse.
+
+Returns:
+
+	time.Time: The parsed date.
+	error: Any errors that occur.
+*/
+func parseIndexDate(date string) (time.Time, error) {
+	if published, err := time.Parse(time.RFC3339, date); err == nil {
+		return published, nil
+	}
+
+	return time.Parse("2006-01-02", date)
+}
+
+/*
+openURL opens a stream to the file at the given http(s):// or file:// URL.
+Arguments:
+
+	ctx<context.Context>: The context for the request.
+	client<*http.Client>: The client to make HTTP requests with.
+	rawURL<string>: The URL to open.
+
+Returns:
+
+	io.ReadCloser: The contents of the file.
+	int64: The size of the file, or -1 if unknown.
+	error: Any errors that occur.
+*/
+func openURL(ctx context.Context, client *http.Client, rawURL string) (io.ReadCloser, int64, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if parsed.Scheme != "file" {
+		return openHTTP(ctx, client, rawURL)
+	}
+
+	file, err := os.Open(parsed.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+
+	return file, info.Size(), nil
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testArchiveSHA256 and testArchiveSHA512 are the digests of "foo\n", the contents of the archive served to index sources.
+const (
+	testArchiveSHA256 = "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
+	testArchiveSHA512 = "0cf9180a764aba863a67b6d72f0918bc131c6772642cb2dce5a34f0a702f9470ddc2bf125c12198b1995c233c34b4afd346c54a2334c350a948a51b6e8b4e6b6"
+)
+
+/*
+newTestIndexSource serves the given release index along with an archive at "/GE-Test.tar.gz", and creates a source for it.
+Arguments:
+
+	t<*testing.T>: The test.
+	index<string>: The contents of the index, served at "/index.json" or "/index.yaml" depending on whether it is JSON.
+
+Returns:
+
+	Source: The index source.
+*/
+func newTestIndexSource(t *testing.T, index string) Source {
+	t.Helper()
+
+	// Keep the responses out of the user's metadata cache.
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	name := "/index.yaml"
+	if strings.HasPrefix(index, "{") {
+		name = "/index.json"
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case name:
+			w.Write([]byte(index))
+		case "/GE-Test.tar.gz":
+			w.Write([]byte("foo\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	source, err := newIndexSource(server.URL+name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return source
+}
+
+func TestIndexSourceChecksums(t *testing.T) {
+	tests := []struct {
+		name    string
+		index   string
+		sumName string
+	}{
+		{
+			name:    "sha256 checksum",
+			index:   "releases:\n  - tag: GE-Test\n    assets:\n      - name: GE-Test.tar.gz\n        url: GE-Test.tar.gz\n        checksum: sha256:" + testArchiveSHA256 + "\n",
+			sumName: "GE-Test.tar.gz.sha256sum",
+		},
+		{
+			name:    "sha512 checksum",
+			index:   "releases:\n  - tag: GE-Test\n    assets:\n      - name: GE-Test.tar.gz\n        url: GE-Test.tar.gz\n        checksum: SHA512:" + testArchiveSHA512 + "\n",
+			sumName: "GE-Test.tar.gz.sha512sum",
+		},
+		{
+			name:    "unprefixed checksum",
+			index:   "releases:\n  - tag: GE-Test\n    assets:\n      - name: GE-Test.tar.gz\n        url: GE-Test.tar.gz\n        checksum: " + testArchiveSHA256 + "\n",
+			sumName: "GE-Test.tar.gz.sha256sum",
+		},
+		{
+			name:    "legacy sha512 field",
+			index:   `{"releases": [{"tag": "GE-Test", "assets": [{"name": "GE-Test.tar.gz", "url": "GE-Test.tar.gz", "sha512": "` + testArchiveSHA512 + `"}]}]}`,
+			sumName: "GE-Test.tar.gz.sha512sum",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := newTestIndexSource(t, test.index)
+
+			release, err := source.GetRelease(context.Background(), "GE-Test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tar, sum, err := GetValidAssets(release.Assets, nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sum == nil || sum.Name != test.sumName {
+				t.Fatalf("checksum asset = %+v, want %s", sum, test.sumName)
+			}
+
+			dir := t.TempDir()
+			if _, err := DownloadAsset(filepath.Join(dir, sum.Name), source, sum); err != nil {
+				t.Fatal(err)
+			}
+
+			verifier, err := NewChecksumFileVerifier(filepath.Join(dir, sum.Name), tar.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			archivePath := filepath.Join(dir, tar.Name)
+			if _, err := DownloadAsset(archivePath, source, tar); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := copyFile(verifier, archivePath); err != nil {
+				t.Fatal(err)
+			}
+
+			if result := verifier.Result(); !result.Match {
+				t.Errorf("checksum of the downloaded archive did not match: %+v", result)
+			}
+		})
+	}
+}
+
+func TestIndexSourceInvalidChecksums(t *testing.T) {
+	tests := map[string]string{
+		"unsupported algorithm": "crc32:8c736521",
+		"empty digest":          "sha256:",
+		"wrong length":          "sha256:" + testArchiveSHA512,
+	}
+
+	for name, checksum := range tests {
+		t.Run(name, func(t *testing.T) {
+			source := newTestIndexSource(t, "releases:\n  - tag: GE-Test\n    assets:\n      - name: GE-Test.tar.gz\n        url: GE-Test.tar.gz\n        checksum: \""+checksum+"\"\n")
+
+			if _, err := source.ListReleases(context.Background(), 0); err == nil {
+				t.Errorf("ListReleases() with checksum %s succeeded, want an error", checksum)
+			}
+		})
+	}
+}
+
+func TestIndexSourceWithoutChecksum(t *testing.T) {
+	source := newTestIndexSource(t, "releases:\n  - tag: GE-Test\n    assets:\n      - name: GE-Test.tar.gz\n        url: GE-Test.tar.gz\n")
+
+	releases, err := source.ListReleases(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(releases) != 1 || len(releases[0].Assets) != 1 {
+		t.Fatalf("ListReleases() = %+v, want one release with only the archive", releases)
+	}
+
+	want := strings.TrimSuffix(strings.TrimPrefix(source.String(), "index:"), "index.yaml") + "GE-Test.tar.gz"
+	if got := releases[0].Assets[0].DownloadURL; got != want {
+		t.Errorf("archive URL = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// SpaceRequirement is an amount of disk space that an install needs in a directory.
+type SpaceRequirement struct {
+	Path string
+	Size int64
+}
+
+// InsufficientSpaceError is returned when a filesystem doesn't have enough free space for an install.
+type InsufficientSpaceError struct {
+	Path      string
+	Needed    int64
+	Available int64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	needed, neededUnit := HumanReadableBytes(e.Needed)
+	available, availableUnit := HumanReadableBytes(e.Available)
+	return fmt.Sprintf("not enough free space for %s, about %v%s is needed but only %v%s is available", e.Path, needed, neededUnit, available, availableUnit)
+}
+
+/*
+EstimateInstallSize estimates how much space the files inside of an archive take up once extracted, based on how well its format usually compresses runners.
+Arguments:
+
+	name<string>: The file name of the archive.
+	size<int64>: The size of the archive in bytes.
+
+Example:
+
+	size := EstimateInstallSize("GE-Proton8-25.tar.gz", 440401920)
+	fmt.Println(size) // 1321205760
+
+Returns:
+
+	int64: The estimated size of the extracted files in bytes, or the size of the archive if it is not a supported archive.
+*/
+func EstimateInstallSize(name string, size int64) int64 {
+	format := getArchiveFormat(name)
+	if format == nil {
+		return size
+	}
+
+	return int64(float64(size) * format.expansion)
+}
+
+/*
+GetInstallSize returns how much space the files inside of the archive at the given path take up once extracted.
+Zip files list the size of every file in their index so the exact size is used, other formats are estimated with EstimateInstallSize.
+Arguments:
+
+	archivePath<string>: The path to the archive.
+
+Example:
+
+	size, err := GetInstallSize("/tmp/proto/dxvk-2.3.zip")
+
+Returns:
+
+	int64: The size of the extracted files in bytes.
+	error: An error if one occurs.
+*/
+func GetInstallSize(archivePath string) (int64, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return 0, err
+	}
+
+	if format := getArchiveFormat(archivePath); format == nil || format.extension != ".zip" {
+		return EstimateInstallSize(archivePath, info.Size()), nil
+	}
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return 0, err
+	}
+
+	defer reader.Close()
+
+	var size int64
+	for _, file := range reader.File {
+		size += int64(file.UncompressedSize64)
+	}
+
+	return size, nil
+}
+
+/*
+CheckFreeSpace checks that there is enough free space for every requirement, adding together requirements that are on the
+same filesystem. Directories that don't exist yet are checked on the filesystem they will be created on.
+Arguments:
+
+	requirements<...SpaceRequirement>: The space needed in each directory.
+
+Example:
+
+	err := CheckFreeSpace(SpaceRequirement{Path: "/tmp/proto/", Size: 440401920}, SpaceRequirement{Path: "$HOME/.steam/root/compatibilitytools.d/", Size: 1321205760})
+
+Returns:
+
+	error: An InsufficientSpaceError for the filesystem that is too full, or any other errors that occur.
+*/
+func CheckFreeSpace(requirements ...SpaceRequirement) error {
+	type filesystem struct {
+		path      string
+		needed    int64
+		available int64
+	}
+
+	var filesystems []*filesystem
+	byDevice := map[uint64]*filesystem{}
+	for _, requirement := range requirements {
+		if requirement.Size <= 0 {
+			continue
+		}
+
+		path, err := existingParent(requirement.Path)
+		if err != nil {
+			return err
+		}
+
+		var stat syscall.Stat_t
+		if err := syscall.Stat(path, &stat); err != nil {
+			return &os.PathError{Op: "stat", Path: path, Err: err}
+		}
+
+		fs, ok := byDevice[uint64(stat.Dev)]
+		if !ok {
+			var statfs syscall.Statfs_t
+			if err := syscall.Statfs(path, &statfs); err != nil {
+				return &os.PathError{Op: "statfs", Path: path, Err: err}
+			}
+
+			fs = &filesystem{path: requirement.Path, available: int64(statfs.Bavail) * int64(statfs.Bsize)}
+			byDevice[uint64(stat.Dev)] = fs
+			filesystems = append(filesystems, fs)
+		}
+
+		fs.needed += requirement.Size
+	}
+
+	for _, fs := range filesystems {
+		Debug(fmt.Sprintf("CheckFreeSpace: %s needs %d bytes and has %d bytes available", fs.path, fs.needed, fs.available))
+
+		if fs.needed > fs.available {
+			return &InsufficientSpaceError{Path: fs.path, Needed: fs.needed, Available: fs.available}
+		}
+	}
+
+	return nil
+}
+
+/*
+existingParent returns the given path if it exists, otherwise the closest parent directory that does.
+Arguments:
+
+	path<string>: The path to look up.
+
+Returns:
+
+	string: The closest existing path.
+	error: An error if one occurs.
+*/
+func existingParent(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if _, err := os.Stat(path); err == nil || !os.IsNotExist(err) {
+			return path, err
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		path = parent
+	}
+}